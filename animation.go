@@ -0,0 +1,199 @@
+package touch
+
+import (
+	"image"
+	"image/color"
+	"time"
+)
+
+// DefaultAnimationDuration is the duration of animations created by this package.
+const DefaultAnimationDuration = 250 * time.Millisecond
+
+// Animation interpolates a value over time, once per frame of its RunLoop.
+// Animations and their callbacks run on the runloop.
+type Animation struct {
+	Duration time.Duration
+	// Curve eases the animation's progress; Defaults to EaseInOut.
+	Curve Curve
+	// Update is called each frame with the eased progress, from 0 to 1.
+	Update func(progress float64)
+	// Completion, if set, is called when the animation ends.
+	// finished is false if the animation was canceled.
+	Completion func(finished bool)
+
+	// begin captures starting values when the animation is started,
+	// and end cleans up before Completion is called.
+	begin    func()
+	end      func(finished bool)
+	next     *Animation
+	runloop  *RunLoop
+	callback *FrameCallback
+	start    time.Time
+}
+
+// Then chains next to start when a finishes, and returns next.
+// Canceled animations do not start their successors.
+func (a *Animation) Then(next *Animation) *Animation {
+	a.next = next
+	return next
+}
+
+// Start begins the animation on runloop. Must be called from the runloop.
+// If runloop is nil, such as for layers not in a window, the animation
+// and its successors complete immediately.
+func (a *Animation) Start(runloop *RunLoop) *Animation {
+	a.Cancel()
+	if a.begin != nil {
+		a.begin()
+	}
+	a.runloop = runloop
+	if runloop == nil {
+		a.step(time.Time{})
+		return a
+	}
+	a.start = runloop.clock().Now()
+	a.callback = runloop.AddFrameCallback(a.step)
+	return a
+}
+
+// Cancel stops the animation where it is.
+func (a *Animation) Cancel() {
+	if a.IsRunning() {
+		a.finish(false)
+	}
+}
+
+func (a *Animation) IsRunning() bool {
+	return a.callback != nil
+}
+
+func (a *Animation) step(frameTime time.Time) {
+	progress := 1.0
+	if a.runloop != nil && a.Duration > 0 {
+		progress = float64(frameTime.Sub(a.start)) / float64(a.Duration)
+	}
+	if progress >= 1 {
+		a.Update(1)
+		a.finish(true)
+		return
+	}
+
+	curve := a.Curve
+	if curve == nil {
+		curve = EaseInOut
+	}
+	a.Update(curve(progress))
+}
+
+func (a *Animation) finish(finished bool) {
+	if a.callback != nil {
+		a.callback.Remove()
+		a.callback = nil
+	}
+	if a.end != nil {
+		a.end(finished)
+	}
+	if a.Completion != nil {
+		a.Completion(finished)
+	}
+	if finished && a.next != nil {
+		a.next.Start(a.runloop)
+	}
+}
+
+// AnimateFrame returns an animation that moves layer from its current frame to frame.
+// The frames of the layer's children are not changed.
+func AnimateFrame(layer Layer, frame image.Rectangle) *Animation {
+	var from image.Rectangle
+	return &Animation{
+		Duration: DefaultAnimationDuration,
+		begin:    func() { from = layer.Frame() },
+		Update: func(p float64) {
+			layer.SetFrame(image.Rectangle{
+				Min: lerpPoint(from.Min, frame.Min, p),
+				Max: lerpPoint(from.Max, frame.Max, p),
+			})
+		},
+	}
+}
+
+// AnimateBackground returns an animation that fades layer's background to c.
+func AnimateBackground(layer *BasicLayer, c color.Color) *Animation {
+	var from color.Color
+	return &Animation{
+		Duration: DefaultAnimationDuration,
+		begin:    func() { from = layer.Background },
+		Update: func(p float64) {
+			layer.Background = lerpColor(from, c, p)
+			layer.Invalidate()
+		},
+	}
+}
+
+// AnimateOpacity returns an animation that fades layer to the given opacity.
+func AnimateOpacity(layer *BasicLayer, opacity float64) *Animation {
+	var from float64
+	return &Animation{
+		Duration: DefaultAnimationDuration,
+		begin:    func() { from = layer.Opacity() },
+		Update: func(p float64) {
+			layer.SetOpacity(from + (opacity-from)*p)
+		},
+	}
+}
+
+// AnimateTextColor returns an animation that fades the color of layer's text to c.
+func AnimateTextColor(layer *TextLayer, c color.Color) *Animation {
+	var from color.Color
+	return &Animation{
+		Duration: DefaultAnimationDuration,
+		begin:    func() { from = layer.Color },
+		Update: func(p float64) {
+			layer.Color = lerpColor(from, c, p)
+			layer.Invalidate()
+		},
+	}
+}
+
+func lerpPoint(from, to image.Point, p float64) image.Point {
+	return image.Point{
+		X: from.X + int(float64(to.X-from.X)*p),
+		Y: from.Y + int(float64(to.Y-from.Y)*p),
+	}
+}
+
+// lerpColor interpolates premultiplied colors. Nil colors are transparent,
+// but a nil destination is returned as nil when p reaches 1, since layers treat
+// a nil Background differently from a transparent one.
+func lerpColor(from, to color.Color, p float64) color.Color {
+	if p >= 1 {
+		return to
+	}
+	if from == nil {
+		from = color.Transparent
+	}
+	if to == nil {
+		to = color.Transparent
+	}
+	r0, g0, b0, a0 := from.RGBA()
+	r1, g1, b1, a1 := to.RGBA()
+	lerp := func(v0, v1 uint32) uint16 {
+		v := float64(v0) + (float64(v1)-float64(v0))*p
+		// Springs may overshoot their endpoints
+		if v < 0 {
+			return 0
+		} else if v > 0xFFFF {
+			return 0xFFFF
+		}
+		return uint16(v)
+	}
+	a := lerp(a0, a1)
+	// Keep the premultiplied color channels no greater than alpha
+	clamp := func(v uint16) uint16 {
+		if v > a {
+			return a
+		}
+		return v
+	}
+	return color.RGBA64{R: clamp(lerp(r0, r1)), G: clamp(lerp(g0, g1)), B: clamp(lerp(b0, b1)), A: a}
+}
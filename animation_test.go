@@ -0,0 +1,260 @@
+package touch_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+func TestCurves(t *testing.T) {
+	curves := []struct {
+		name  string
+		curve touch.Curve
+		// Whether the curve may overshoot its endpoints
+		overshoots bool
+	}{
+		{"Linear", touch.Linear, false},
+		{"EaseIn", touch.EaseIn, false},
+		{"EaseOut", touch.EaseOut, false},
+		{"EaseInOut", touch.EaseInOut, false},
+		{"Bezier Linear", touch.CubicBezier(1.0/3, 1.0/3, 2.0/3, 2.0/3), false},
+		{"Bezier Steep", touch.CubicBezier(0.9, 0, 0.1, 1), false},
+		{"Spring", touch.Spring(0.5, 2), true},
+		{"Loose Spring", touch.Spring(0.05, 1), true},
+		{"Stiff Spring", touch.Spring(0.99, 0), true},
+	}
+	for _, c := range curves {
+		t.Run(c.name, func(t *testing.T) {
+			if v := c.curve(0); math.Abs(v) > 1e-6 {
+				t.Errorf("Expected to start at 0, got %v", v)
+			}
+			if v := c.curve(1); v != 1 {
+				t.Errorf("Expected to end at 1, got %v", v)
+			}
+			prev := 0.0
+			for i := 1; i < 100; i++ {
+				v := c.curve(float64(i) / 100)
+				if math.IsNaN(v) {
+					t.Fatalf("NaN at %d%%", i)
+				}
+				if !c.overshoots && (v < prev-1e-6 || v > 1) {
+					t.Fatalf("Expected monotonic progress within [0, 1], got %v after %v at %d%%", v, prev, i)
+				}
+				prev = v
+			}
+			if c.overshoots && math.Abs(prev-1) > 0.01 {
+				t.Errorf("Expected spring to settle before the end, got %v at 99%%", prev)
+			}
+			if v := c.curve(1 - 1e-9); c.overshoots && math.Abs(v-1) > 0.001 {
+				t.Errorf("Expected spring to be within 0.1%% of rest at the end, got %v", v)
+			}
+		})
+	}
+
+	t.Run("Bezier Values", func(t *testing.T) {
+		if v := touch.EaseInOut(0.5); math.Abs(v-0.5) > 1e-4 {
+			t.Errorf("EaseInOut should be symmetric about 0.5, got %v", v)
+		}
+		if v := touch.CubicBezier(1.0/3, 1.0/3, 2.0/3, 2.0/3)(0.3); math.Abs(v-0.3) > 1e-4 {
+			t.Errorf("Bezier with linear control points should be linear, got %v", v)
+		}
+		if touch.EaseIn(0.25) >= 0.25 || touch.EaseOut(0.25) <= 0.25 {
+			t.Errorf("EaseIn should start slow and EaseOut fast: %v, %v", touch.EaseIn(0.25), touch.EaseOut(0.25))
+		}
+	})
+	t.Run("Spring Overshoots", func(t *testing.T) {
+		spring, peak := touch.Spring(0.2, 2), 0.0
+		for i := 0; i <= 100; i++ {
+			peak = math.Max(peak, spring(float64(i)/100))
+		}
+		if peak <= 1 {
+			t.Errorf("Expected a lightly damped spring to overshoot, peak %v", peak)
+		}
+	})
+}
+
+func TestLerpColor(t *testing.T) {
+	red := color.RGBA{0xFF, 0, 0, 0xFF}
+	blue := color.RGBA{0, 0, 0xFF, 0xFF}
+	rgba := func(c color.Color) color.RGBA {
+		if c == nil {
+			return color.RGBA{}
+		}
+		return color.RGBAModel.Convert(c).(color.RGBA)
+	}
+	tests := []struct {
+		name     string
+		from, to color.Color
+		p        float64
+		expected color.RGBA
+	}{
+		{"Start", red, blue, 0, red},
+		{"Midpoint", red, blue, 0.5, color.RGBA{0x7F, 0, 0x7F, 0xFF}},
+		{"End", red, blue, 1, blue},
+		{"From Nil", nil, blue, 0.5, color.RGBA{0, 0, 0x7F, 0x7F}},
+		{"To Nil", red, nil, 0.5, color.RGBA{0x7F, 0, 0, 0x7F}},
+		{"Undershoot Clamps", red, blue, -0.5, color.RGBA{0xFF, 0, 0, 0xFF}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if c := rgba(touch.LerpColor(test.from, test.to, test.p)); c != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, c)
+			}
+		})
+	}
+	t.Run("Nil Is Restored At End", func(t *testing.T) {
+		if c := touch.LerpColor(red, nil, 1); c != nil {
+			t.Errorf("Expected nil, got %v", c)
+		}
+		if c := touch.LerpColor(red, nil, 1.2); c != nil {
+			t.Errorf("Expected nil after overshoot, got %v", c)
+		}
+	})
+	t.Run("Animated Background Ends Nil", func(t *testing.T) {
+		layer := &touch.BasicLayer{Background: red}
+		layer.Self = layer
+		// Without a runloop, animations complete immediately
+		touch.AnimateBackground(layer, nil).Start(nil)
+		if layer.Background != nil {
+			t.Errorf("Expected nil background, got %v", layer.Background)
+		}
+	})
+}
+
+func TestAnimation(t *testing.T) {
+	const frame = 100 * time.Millisecond
+	from, to, next := image.Rect(0, 0, 4, 4), image.Rect(12, 0, 16, 4), image.Rect(12, 12, 16, 16)
+
+	// startLayer runs a window with a layer, drawing a frame every 100ms of a manual clock.
+	startLayer := func(t *testing.T) (*touch.RunLoop, *touch.ManualClock, *touch.BasicLayer) {
+		clock := touch.NewManualClock(time.Unix(0, 0))
+		runloop := startHeadless(t, clock)
+		layer := &touch.BasicLayer{}
+		runloop.Do(func() error {
+			runloop.MaxFPS = 10
+			layer.Self = layer
+			layer.SetFrame(from)
+			runloop.Window.AddChild(layer)
+			return nil
+		})
+		return runloop, clock, layer
+	}
+	advance := func(runloop *touch.RunLoop, clock *touch.ManualClock, frames int) {
+		for ; frames > 0; frames-- {
+			clock.Advance(frame)
+			waitForTasks(runloop)
+		}
+	}
+	// animate returns a linear, three-frame animation of layer's frame, recording its completions.
+	animate := func(layer *touch.BasicLayer, target image.Rectangle, completions *[]bool) *touch.Animation {
+		a := touch.AnimateFrame(layer, target)
+		a.Duration, a.Curve = 300*time.Millisecond, touch.Linear
+		a.Completion = func(finished bool) {
+			*completions = append(*completions, finished)
+		}
+		return a
+	}
+	// check compares the layer's frame and the recorded completions, from the runloop.
+	check := func(t *testing.T, runloop *touch.RunLoop, layer *touch.BasicLayer, rect image.Rectangle, completions *[]bool, want ...bool) {
+		t.Helper()
+		runloop.Do(func() error {
+			if layer.Frame() != rect {
+				t.Errorf("Expected frame %v, got %v", rect, layer.Frame())
+			}
+			if len(*completions) != len(want) {
+				t.Errorf("Expected completions %v, got %v", want, *completions)
+				return nil
+			}
+			for idx := range want {
+				if (*completions)[idx] != want[idx] {
+					t.Errorf("Expected completions %v, got %v", want, *completions)
+				}
+			}
+			return nil
+		})
+	}
+
+	t.Run("Animation Reaches Its Final Value", func(t *testing.T) {
+		runloop, clock, layer := startLayer(t)
+		var completions []bool
+		a := animate(layer, to, &completions)
+		runloop.Do(func() error {
+			a.Start(runloop)
+			return nil
+		})
+		advance(runloop, clock, 1)
+		check(t, runloop, layer, image.Rect(4, 0, 8, 4), &completions)
+		advance(runloop, clock, 2)
+		check(t, runloop, layer, to, &completions, true)
+		runloop.Do(func() error {
+			if a.IsRunning() {
+				t.Error("Expected the animation to stop when it finishes")
+			}
+			return nil
+		})
+		// Finished animations are not completed again
+		advance(runloop, clock, 3)
+		check(t, runloop, layer, to, &completions, true)
+	})
+	t.Run("Canceled Animation Stops Where It Is", func(t *testing.T) {
+		runloop, clock, layer := startLayer(t)
+		var completions []bool
+		a := animate(layer, to, &completions)
+		b := animate(layer, next, &completions)
+		a.Then(b)
+		runloop.Do(func() error {
+			a.Start(runloop)
+			return nil
+		})
+		advance(runloop, clock, 1)
+		runloop.Do(func() error {
+			a.Cancel()
+			return nil
+		})
+		check(t, runloop, layer, image.Rect(4, 0, 8, 4), &completions, false)
+		// Its successor is not started
+		advance(runloop, clock, 6)
+		check(t, runloop, layer, image.Rect(4, 0, 8, 4), &completions, false)
+	})
+	t.Run("Then Starts The Next Animation", func(t *testing.T) {
+		runloop, clock, layer := startLayer(t)
+		var completions []bool
+		a := animate(layer, to, &completions)
+		b := animate(layer, next, &completions)
+		if a.Then(b) != b {
+			t.Error("Expected Then to return the next animation")
+		}
+		runloop.Do(func() error {
+			a.Start(runloop)
+			return nil
+		})
+		advance(runloop, clock, 3)
+		check(t, runloop, layer, to, &completions, true)
+		runloop.Do(func() error {
+			if !b.IsRunning() {
+				t.Error("Expected the next animation to start when the first finishes")
+			}
+			return nil
+		})
+		advance(runloop, clock, 1)
+		check(t, runloop, layer, image.Rect(12, 4, 16, 8), &completions, true)
+		advance(runloop, clock, 2)
+		check(t, runloop, layer, next, &completions, true, true)
+	})
+	t.Run("Animation Without RunLoop Completes Immediately", func(t *testing.T) {
+		layer := &touch.BasicLayer{}
+		layer.Self = layer
+		layer.SetFrame(from)
+		var completions []bool
+		a := animate(layer, to, &completions)
+		a.Then(animate(layer, next, &completions))
+		a.Start(nil)
+		if layer.Frame() != next || len(completions) != 2 || !completions[0] || !completions[1] {
+			t.Errorf("Expected both animations to complete, got frame %v and completions %v", layer.Frame(), completions)
+		}
+	})
+}
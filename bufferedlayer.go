@@ -2,6 +2,7 @@ package touch
 
 import (
 	"image"
+	"image/color"
 	"image/draw"
 )
 
@@ -24,18 +25,34 @@ func (layer *BufferedLayer) InvalidateRect(rect image.Rectangle) {
 }
 
 func (layer *BufferedLayer) RenderBuffer() {
-	buffer := &layer.Buffer
 	for _, rect := range layer.invalid.Dequeue() {
-		layer.BasicLayer.Render(buffer.Clip(rect))
+		layer.drawBuffer(rect)
 	}
 }
 
-// Render draws and composites any invalid regions to the buffer
+// drawBuffer clears rect of the buffer, and renders the layer's contents into it.
+// Opacity is not applied here, but when the buffer is composited, so that it is applied only once.
+func (layer *BufferedLayer) drawBuffer(rect image.Rectangle) DrawingContext {
+	ctx := layer.Buffer.Clip(rect)
+	draw.Draw(ctx.Image(), ctx.Bounds(), image.Transparent, image.Point{}, draw.Src)
+	ctx.SetDirty(ctx.Bounds())
+	if layer.Visible() {
+		layer.renderContents(ctx)
+	}
+	return ctx
+}
+
+// Render draws and composites any invalid regions to the buffer.
+// Hidden layers keep their invalid regions until they are shown.
 func (layer *BufferedLayer) Render(ctx DrawingContext) {
+	if !layer.Visible() {
+		return
+	}
 	layer.RenderBuffer()
 
 	if rect := layer.Buffer.Rect.Intersect(ctx.Bounds()); !rect.Empty() {
-		draw.Draw(ctx.Image(), rect, layer.Buffer.RGBA, rect.Min, draw.Over)
+		mask := image.NewUniform(color.Alpha{0xFF - layer.transparency})
+		draw.DrawMask(ctx.Image(), rect, layer.Buffer.RGBA, rect.Min, mask, image.Point{}, draw.Over)
 		ctx.SetDirty(rect)
 	}
 }
@@ -15,7 +15,7 @@ type Button struct {
 	Icon    ImageLayer
 	Actions [ControlActionsCount]func(*Button)
 	Colors  struct {
-		Normal, Highlighted, Disabled ButtonColors
+		Normal, Highlighted, Disabled, Focused ButtonColors
 	}
 
 	Spacing int // Min. Distance between Icon and Label
@@ -41,6 +41,9 @@ func (b *Button) Init(frame image.Rectangle, labelFont string, size float64) {
 	b.Colors.Normal.Background = color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
 	b.Colors.Normal.Text = color.Gray{0x00}
 
+	b.Colors.Focused.Background = color.RGBA{R: 0xFF, G: 0xD8, B: 0x80, A: 0xFF}
+	b.Colors.Focused.Text = color.Gray{0x00}
+
 	b.StateDidChange()
 }
 
@@ -70,6 +73,8 @@ func (b *Button) StateDidChange() {
 		b.ApplyColors(b.Colors.Disabled)
 	} else if b.IsHighlighted() {
 		b.ApplyColors(b.Colors.Highlighted)
+	} else if b.IsFocused() {
+		b.ApplyColors(b.Colors.Focused)
 	} else {
 		b.ApplyColors(b.Colors.Normal)
 	}
@@ -10,6 +10,7 @@ const (
 	ControlStateNormal      ControlStateMask = 0
 	ControlStateHighlighted ControlStateMask = 1 << iota
 	ControlStateDisabled
+	ControlStateFocused
 )
 
 type ControlAction int
@@ -20,11 +21,14 @@ const (
 	ControlActionsCount
 )
 
+// LongPressDuration is how long a control must be held to trigger ControlLongPress.
+const LongPressDuration = 400 * time.Millisecond
+
 type ControlLayer struct {
 	BasicLayer
 	State          ControlStateMask
 	touchOrigin    TouchEvent
-	longpressTimer *time.Timer
+	longpressTimer *Timer
 }
 
 type ControlDelegate interface {
@@ -74,14 +78,49 @@ func (c *ControlLayer) SetDisabled(disabled bool) {
 	}
 }
 
+func (c *ControlLayer) IsFocused() bool {
+	return c.State&ControlStateFocused == ControlStateFocused
+}
+
+func (c *ControlLayer) SetFocused(focused bool) {
+	if focused {
+		c.SetState(c.State | ControlStateFocused)
+	} else {
+		c.SetState(c.State &^ ControlStateFocused)
+	}
+}
+
+// CanFocus reports whether the control may receive the keyboard focus.
+func (c *ControlLayer) CanFocus() bool {
+	return !c.IsDisabled()
+}
+
+/* Key Event Handling; Enter activates the control like a tap. */
+
+func (c *ControlLayer) HandleKey(event KeyEvent) bool {
+	switch event.Key {
+	case KeyEnter, KeyKPEnter, KeySpace, KeySelect, KeyOK:
+	default:
+		return false
+	}
+	if event.Pressed {
+		c.SetHighlighted(true)
+	} else if c.IsHighlighted() {
+		c.TriggerAction(ControlTapped)
+		c.SetHighlighted(false)
+	}
+	return true
+}
+
 /* Touch Event Handling; Button behavior is the default. */
 
 func (c *ControlLayer) StartTouch(event TouchEvent) {
 	c.SetHighlighted(event.In(c.Rectangle))
 	// Start long-press handling
 	c.touchOrigin = event
-	if del, ok := c.Self.(ControlDelegate); ok && del.ShouldHandleAction(ControlLongPress) {
-		c.longpressTimer = time.AfterFunc(400*time.Millisecond, c.dispatchLongPress)
+	runloop := c.Window().RunLoop()
+	if del, ok := c.Self.(ControlDelegate); ok && runloop != nil && del.ShouldHandleAction(ControlLongPress) {
+		c.longpressTimer = runloop.After(LongPressDuration, c.dispatchLongPress)
 	}
 }
 
@@ -108,10 +147,8 @@ func (c *ControlLayer) CancelTouch() {
 /* Long Press Handling (Private) */
 
 func (c *ControlLayer) dispatchLongPress() {
-	MainRunLoop.Tasks <- func() {
-		c.touchOrigin.Cancel()
-		c.TriggerAction(ControlLongPress)
-	}
+	c.touchOrigin.Cancel()
+	c.TriggerAction(ControlLongPress)
 }
 
 func (c *ControlLayer) cancelLongPress() (canceled bool) {
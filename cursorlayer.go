@@ -0,0 +1,61 @@
+package touch
+
+import (
+	"image"
+	"image/color"
+)
+
+// The arrow is drawn with X as its outline and . as its fill.
+var cursorArrow = []string{
+	"X",
+	"XX",
+	"X.X",
+	"X..X",
+	"X...X",
+	"X....X",
+	"X.....X",
+	"X......X",
+	"X.......X",
+	"X........X",
+	"X.....XXXXX",
+	"X..X..X",
+	"X.X X..X",
+	"XX  X..X",
+	"X    X..X",
+	"     XXX",
+}
+
+// CursorLayer draws a mouse pointer whose tip is at the origin of its frame.
+type CursorLayer struct {
+	BasicLayer
+	Outline, Fill color.Color
+}
+
+func (c *CursorLayer) Init() {
+	c.Outline = color.Black
+	c.Fill = color.White
+	c.Self = c
+	c.MoveTo(image.Point{})
+}
+
+// MoveTo positions the tip of the cursor at pt.
+func (c *CursorLayer) MoveTo(pt image.Point) {
+	size := image.Point{len(cursorArrow[10]), len(cursorArrow)}
+	c.SetFrame(image.Rectangle{Min: pt, Max: pt.Add(size)})
+}
+
+func (c *CursorLayer) DrawIn(ctx DrawingContext) {
+	img := ctx.Image()
+	for y, row := range cursorArrow {
+		for x, px := range row {
+			// Set does nothing outside of the clipped image
+			switch px {
+			case 'X':
+				img.Set(c.Min.X+x, c.Min.Y+y, c.Outline)
+			case '.':
+				img.Set(c.Min.X+x, c.Min.Y+y, c.Fill)
+			}
+		}
+	}
+	ctx.SetDirty(c.Rectangle)
+}
@@ -0,0 +1,99 @@
+package touch
+
+import "math"
+
+// Curve maps linear progress from 0 to 1 onto eased progress.
+// Eased values may overshoot 0 or 1, but must end at 1.
+type Curve func(t float64) float64
+
+var (
+	Linear    Curve = func(t float64) float64 { return t }
+	EaseIn          = CubicBezier(0.42, 0, 1, 1)
+	EaseOut         = CubicBezier(0, 0, 0.58, 1)
+	EaseInOut       = CubicBezier(0.42, 0, 0.58, 1)
+)
+
+// CubicBezier returns a curve with control points (x1, y1) and (x2, y2),
+// like the CSS cubic-bezier() timing function.
+func CubicBezier(x1, y1, x2, y2 float64) Curve {
+	// Polynomial coefficients for each axis; The endpoints are (0, 0) and (1, 1).
+	cx := 3 * x1
+	bx := 3*(x2-x1) - cx
+	ax := 1 - cx - bx
+	cy := 3 * y1
+	by := 3*(y2-y1) - cy
+	ay := 1 - cy - by
+
+	sampleX := func(s float64) float64 { return ((ax*s+bx)*s + cx) * s }
+	sampleY := func(s float64) float64 { return ((ay*s+by)*s + cy) * s }
+	slopeX := func(s float64) float64 { return (3*ax*s+2*bx)*s + cx }
+
+	return func(t float64) float64 {
+		if t <= 0 || t >= 1 {
+			return math.Max(0, math.Min(t, 1))
+		}
+		// Find s such that sampleX(s) == t; Newton's method usually converges quickly.
+		s := t
+		for i := 0; i < 8; i++ {
+			dx := sampleX(s) - t
+			if math.Abs(dx) < 1e-6 {
+				return sampleY(s)
+			}
+			slope := slopeX(s)
+			if math.Abs(slope) < 1e-6 {
+				break
+			}
+			s -= dx / slope
+		}
+		// Fall back to bisection, which always converges.
+		lo, hi := 0.0, 1.0
+		s = t
+		for hi-lo > 1e-6 {
+			if sampleX(s) < t {
+				lo = s
+			} else {
+				hi = s
+			}
+			s = (lo + hi) / 2
+		}
+		return sampleY(s)
+	}
+}
+
+// Spring returns a curve that overshoots and settles like a damped spring.
+// damping is the damping ratio, between 0 and 1; Lower values bounce more.
+// oscillations is the number of times the spring rebounds over the animation.
+// Lightly damped springs are damped further if needed, so that they settle
+// by the end of the animation instead of jumping to their final value.
+func Spring(damping, oscillations float64) Curve {
+	damping = math.Max(0.01, math.Min(damping, 0.99))
+	// Damped and natural angular frequencies, in units of the animation's duration.
+	wd := 2 * math.Pi * math.Max(oscillations, 0)
+	w0 := wd / math.Sqrt(1-damping*damping)
+	// Rate of decay, raised if needed to bring the spring within 0.1% of rest at the end.
+	decayRate := math.Max(damping*w0, math.Log(1000))
+	for springDistance(decayRate, wd) > 0.001 {
+		decayRate *= 1.01
+	}
+	return func(t float64) float64 {
+		if t <= 0 {
+			return 0
+		} else if t >= 1 {
+			return 1
+		}
+		decay := math.Exp(-decayRate * t)
+		if wd == 0 {
+			// Critically damped; the spring returns to rest without rebounding
+			return 1 - decay*(1+decayRate*t)
+		}
+		return 1 - decay*(math.Cos(wd*t)+(decayRate/wd)*math.Sin(wd*t))
+	}
+}
+
+// springDistance returns the greatest distance from rest of a spring at the end of its animation.
+func springDistance(decayRate, wd float64) float64 {
+	if wd == 0 {
+		return math.Exp(-decayRate) * (1 + decayRate)
+	}
+	return math.Exp(-decayRate) * math.Sqrt(1+(decayRate/wd)*(decayRate/wd))
+}
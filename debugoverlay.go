@@ -0,0 +1,170 @@
+package touch
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const debugGraphFrames = 60
+
+// DebugOverlay briefly tints each region of the window as it is redrawn, and
+// draws a graph of recent frame times with the current frame rate.
+// The overlay is drawn over a copy of each frame as it is sent to the display
+// and sinks, and never into the window's buffer. See Window.SetDebugOverlay.
+type DebugOverlay struct {
+	FlashColor    color.Color
+	FlashDuration time.Duration
+	// Graph is where the frame time graph is drawn. Set it empty to hide the graph.
+	Graph image.Rectangle
+
+	window *Window
+	// Frames from oldest to newest, in a ring
+	frames [debugGraphFrames]FrameStats
+	next   int
+	// Regions redrawn within the last FlashDuration
+	flashes []*image.Rectangle
+	// The window's buffer, with the overlay drawn over the regions being flushed
+	output *image.RGBA
+}
+
+// SetDebugOverlay enables or disables the debug overlay.
+func (w *Window) SetDebugOverlay(enabled bool) {
+	if enabled == (w.debug != nil) {
+		return
+	}
+	if enabled {
+		w.debug = &DebugOverlay{
+			FlashColor:    color.RGBA{R: 0x80, B: 0x80, A: 0x80},
+			FlashDuration: 100 * time.Millisecond,
+			Graph:         image.Rect(w.Max.X-130, w.Min.Y+10, w.Max.X-10, w.Min.Y+50),
+			window:        w,
+		}
+		w.flushRect(w.debug.Graph)
+	} else {
+		w.debug = nil
+		// The buffer was never tinted, so flushing it again clears the overlay
+		w.flushRect(w.Buffer.Rect)
+	}
+}
+
+// DebugOverlay returns the window's debug overlay, or nil if it is disabled.
+func (w *Window) DebugOverlay() *DebugOverlay {
+	return w.debug
+}
+
+// composite copies the rects to be flushed from buf into the overlay's output,
+// then draws flashes and the graph over them. Flashes begin for the rects that
+// were rendered this frame, and their regions are flushed again when they end.
+// Returns the output and the rects of it to flush, which include the graph.
+func (d *DebugOverlay) composite(buf *image.RGBA, rendered, rects []image.Rectangle) (*image.RGBA, []image.Rectangle) {
+	if d.output == nil || d.output.Rect != buf.Rect {
+		// Displays that show the whole frame need the regions not being flushed, too
+		d.output = image.NewRGBA(buf.Rect)
+		copyRect(d.output, buf, buf.Rect)
+	}
+	if runloop := d.window.RunLoop(); runloop != nil {
+		for _, rect := range rendered {
+			flash := rect
+			d.flashes = append(d.flashes, &flash)
+			runloop.After(d.FlashDuration, func() { d.endFlash(&flash) })
+		}
+	}
+
+	var regions RegionList
+	for _, rect := range rects {
+		regions.AddRect(rect)
+	}
+	if len(rects) > 0 {
+		regions.AddRect(d.Graph.Intersect(buf.Rect))
+	}
+	rects = append([]image.Rectangle(nil), regions.Dequeue()...)
+
+	tint := image.NewUniform(d.FlashColor)
+	for _, rect := range rects {
+		copyRect(d.output, buf, rect)
+		for _, flash := range d.flashes {
+			if r := flash.Intersect(rect); !r.Empty() {
+				draw.Draw(d.output, r, tint, image.Point{}, draw.Over)
+			}
+		}
+	}
+	d.drawGraph(d.output)
+	return d.output, rects
+}
+
+// endFlash stops tinting a region, and flushes it again without the tint.
+func (d *DebugOverlay) endFlash(flash *image.Rectangle) {
+	for idx, f := range d.flashes {
+		if f == flash {
+			d.flashes = append(d.flashes[:idx], d.flashes[idx+1:]...)
+			break
+		}
+	}
+	if d.window.debug == d {
+		d.window.flushRect(*flash)
+	}
+}
+
+// record adds a frame to the graph, which is drawn over the next frame flushed.
+// Recording doesn't request a frame, which would never let the display go idle.
+func (d *DebugOverlay) record(stats FrameStats) {
+	d.frames[d.next] = stats
+	d.next = (d.next + 1) % len(d.frames)
+}
+
+// fps returns the number of frames started within the second before the latest frame.
+func (d *DebugOverlay) fps() int {
+	latest := d.frames[(d.next+len(d.frames)-1)%len(d.frames)].Start
+	count := 0
+	for _, f := range d.frames {
+		if !f.Start.IsZero() && latest.Sub(f.Start) < time.Second {
+			count++
+		}
+	}
+	return count
+}
+
+// drawGraph draws the frame time graph and frame rate into img.
+func (d *DebugOverlay) drawGraph(img *image.RGBA) {
+	graph := d.Graph.Intersect(img.Rect)
+	if graph.Empty() {
+		return
+	}
+	draw.Draw(img, graph, image.NewUniform(color.RGBA{A: 0xA0}), image.Point{}, draw.Over)
+
+	// One bar per frame, 1px per millisecond of draw and flush time
+	barWidth := d.Graph.Dx() / len(d.frames)
+	for idx := range d.frames {
+		f := d.frames[(d.next+idx)%len(d.frames)]
+		elapsed := f.DrawTime + f.FlushTime
+		height := int(elapsed / time.Millisecond)
+		if height > d.Graph.Dy() {
+			height = d.Graph.Dy()
+		}
+
+		c := color.RGBA{G: 0xC0, A: 0xFF}
+		if elapsed > 33*time.Millisecond {
+			c = color.RGBA{R: 0xE0, A: 0xFF}
+		} else if elapsed > 16*time.Millisecond {
+			c = color.RGBA{R: 0xE0, G: 0xC0, A: 0xFF}
+		}
+		x := d.Graph.Min.X + idx*barWidth
+		bar := image.Rect(x, d.Graph.Max.Y-height, x+barWidth, d.Graph.Max.Y)
+		draw.Draw(img, bar.Intersect(graph), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+
+	drawer := font.Drawer{
+		Dst:  img.SubImage(graph).(*image.RGBA),
+		Src:  image.White,
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(d.Graph.Min.X+4, d.Graph.Min.Y+12),
+	}
+	drawer.DrawString(fmt.Sprintf("%d fps", d.fps()))
+}
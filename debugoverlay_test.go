@@ -0,0 +1,86 @@
+package touch_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+func TestDebugOverlay(t *testing.T) {
+	red := color.RGBA{0xFF, 0, 0, 0xFF}
+	clock := touch.NewManualClock(time.Unix(0, 0))
+	runloop := startHeadless(t, clock)
+	sink := newRecordingSink(nil)
+	layer := &touch.BasicLayer{}
+	runloop.Do(func() error {
+		// Without a background, nothing beneath the layer would redraw over a tint
+		runloop.Window.SetDebugOverlay(true)
+		runloop.Window.AddSink(sink)
+		layer.Self = layer
+		layer.Background = red
+		layer.SetFrame(image.Rect(0, 0, 10, 10))
+		runloop.Window.AddChild(layer)
+		return nil
+	})
+	// sinkPixel waits for a flush that includes x, y, and returns its color there.
+	sinkPixel := func(t *testing.T, x, y int) color.RGBA {
+		t.Helper()
+		for {
+			for _, rect := range sink.wait(t) {
+				if image.Pt(x, y).In(rect) {
+					sink.mu.Lock()
+					defer sink.mu.Unlock()
+					return sink.last.RGBAAt(x, y)
+				}
+			}
+		}
+	}
+
+	t.Run("Redrawn Regions Flash", func(t *testing.T) {
+		if c := sinkPixel(t, 5, 5); c == red {
+			t.Errorf("Expected a tint over the redrawn layer, got %v", c)
+		}
+		if c := pixelAt(runloop, 5, 5); c != red {
+			t.Errorf("Expected the window's buffer to stay untinted, got %v", c)
+		}
+	})
+	t.Run("Flashes End", func(t *testing.T) {
+		clock.Advance(100 * time.Millisecond)
+		if c := sinkPixel(t, 5, 5); c != red {
+			t.Errorf("Expected the tint to be removed, got %v", c)
+		}
+	})
+	t.Run("Graph Is Drawn Over Frames", func(t *testing.T) {
+		graph := runloop.Window.DebugOverlay().Graph
+		pt := graph.Intersect(image.Rect(0, 0, 32, 32)).Min
+		// Sinks receive whole frames, and the graph was included in the last
+		sink.mu.Lock()
+		c := sink.last.RGBAAt(pt.X, pt.Y)
+		sink.mu.Unlock()
+		if c.A == 0 {
+			t.Errorf("Expected the graph at %v, got %v", pt, c)
+		}
+		if c := pixelAt(runloop, pt.X, pt.Y); c.A != 0 {
+			t.Errorf("Expected the graph not to be drawn into the window's buffer, got %v", c)
+		}
+	})
+	t.Run("Disabling Removes Tints", func(t *testing.T) {
+		runloop.Do(func() error {
+			layer.Invalidate()
+			return nil
+		})
+		if c := sinkPixel(t, 5, 5); c == red {
+			t.Fatalf("Expected a tint over the redrawn layer, got %v", c)
+		}
+		runloop.Do(func() error {
+			runloop.Window.SetDebugOverlay(false)
+			return nil
+		})
+		if c := sinkPixel(t, 5, 5); c != red {
+			t.Errorf("Expected the tint to be removed, got %v", c)
+		}
+	})
+}
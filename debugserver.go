@@ -0,0 +1,214 @@
+package touch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/draw"
+	"image/png"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultDebugAddr is the address DebugServer listens on if Addr is empty.
+const DefaultDebugAddr = "localhost:7070"
+
+// DebugTapDuration is how long taps posted to DebugServer are held,
+// long enough for the highlight to be drawn.
+const DebugTapDuration = 50 * time.Millisecond
+
+// DebugServer serves the screen, layer tree and frame metrics of a RunLoop
+// over HTTP, and accepts injected taps:
+//
+//	GET  /screen.png        Current contents of the window
+//	GET  /layers            Layer tree, as JSON
+//	GET  /metrics           Frame statistics and runloop queue depth, as JSON
+//	POST /tap?x=...&y=...   Tap at the given screen coordinates; Responds once pressed
+//
+// There is no authentication; listen on localhost and connect through an SSH tunnel.
+type DebugServer struct {
+	// Address to listen on; DefaultDebugAddr if empty.
+	Addr string
+
+	runloop *RunLoop
+	stats   *FrameStatsAggregator
+
+	mu      sync.Mutex
+	serving bool
+}
+
+// DebugMetrics is the JSON body served from /metrics.
+type DebugMetrics struct {
+	Frames FrameStatsSummary
+	// Tasks waiting in the runloop's Tasks channel and Post queue
+	QueuedTasks int
+}
+
+// NewDebugServer returns a DebugServer for runloop, which must be initialized.
+func NewDebugServer(runloop *RunLoop) *DebugServer {
+	return &DebugServer{
+		runloop: runloop,
+		stats:   NewFrameStatsAggregator(120),
+	}
+}
+
+// Handler returns the debug endpoints, for mounting on an existing server.
+// Frame metrics are only collected by ListenAndServe.
+func (s *DebugServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/screen.png", s.serveScreen)
+	mux.HandleFunc("/layers", s.serveLayers)
+	mux.HandleFunc("/metrics", s.serveMetrics)
+	mux.HandleFunc("/tap", s.serveTap)
+	return mux
+}
+
+// ListenAndServe collects frame metrics and serves the debug endpoints until ctx is done.
+// Any Window.OnFrame handler set before calling ListenAndServe continues to be called,
+// and is restored when it returns. Each DebugServer may only serve once at a time.
+func (s *DebugServer) ListenAndServe(ctx context.Context) error {
+	s.mu.Lock()
+	if s.serving {
+		s.mu.Unlock()
+		return errors.New("debug server is already serving")
+	}
+	s.serving = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.serving = false
+		s.mu.Unlock()
+	}()
+
+	addr := s.Addr
+	if addr == "" {
+		addr = DefaultDebugAddr
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	win := s.runloop.Window
+	// Only accessed on the runloop
+	var onFrame func(FrameStats)
+	s.runloop.Post(func() {
+		onFrame = win.OnFrame
+		win.OnFrame = func(stats FrameStats) {
+			if onFrame != nil {
+				onFrame(stats)
+			}
+			s.stats.Record(stats)
+		}
+	})
+	defer s.runloop.Post(func() {
+		win.OnFrame = onFrame
+	})
+
+	return serveUntilDone(ctx, listener, s.Handler())
+}
+
+// serveUntilDone serves HTTP requests on listener until ctx is done.
+func serveUntilDone(ctx context.Context, listener net.Listener, handler http.Handler) error {
+	server := &http.Server{Handler: handler}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// do runs fn on the runloop, giving up if the request is canceled first.
+func (s *DebugServer) do(r *http.Request, fn func() error) error {
+	f := s.runloop.DoAsync(fn)
+	select {
+	case <-f.Done():
+		return f.Wait()
+	case <-r.Context().Done():
+		return r.Context().Err()
+	}
+}
+
+func (s *DebugServer) serveScreen(w http.ResponseWriter, r *http.Request) {
+	var screen *image.RGBA
+	err := s.do(r, func() error {
+		buf := s.runloop.Window.RGBA
+		screen = image.NewRGBA(buf.Rect)
+		draw.Draw(screen, screen.Rect, buf, buf.Rect.Min, draw.Src)
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	png.Encode(w, screen)
+}
+
+func (s *DebugServer) serveLayers(w http.ResponseWriter, r *http.Request) {
+	var info LayerInfo
+	err := s.do(r, func() error {
+		info = InspectLayer(s.runloop.Window)
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, info)
+}
+
+func (s *DebugServer) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	runloop := s.runloop
+	runloop.queueMu.Lock()
+	queued := len(runloop.queue)
+	runloop.queueMu.Unlock()
+
+	writeJSON(w, DebugMetrics{
+		Frames:      s.stats.Summary(),
+		QueuedTasks: queued + len(runloop.tasks),
+	})
+}
+
+func (s *DebugServer) serveTap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "tap requires POST", http.StatusMethodNotAllowed)
+		return
+	}
+	x, errX := strconv.Atoi(r.FormValue("x"))
+	y, errY := strconv.Atoi(r.FormValue("y"))
+	if errX != nil || errY != nil {
+		http.Error(w, "x and y must be integers", http.StatusBadRequest)
+		return
+	}
+	// Release on the runloop's clock, rather than blocking the request
+	err := s.do(r, func() error {
+		pt := image.Pt(x, y)
+		s.runloop.dispatchTouch(TouchEvent{Point: pt, Pressed: true, Pressure: 0xFF})
+		s.runloop.After(DebugTapDuration, func() {
+			s.runloop.dispatchTouch(TouchEvent{Point: pt, Pressure: 0xFF})
+		})
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,187 @@
+package touch_test
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+// touchLayer records the touches it receives.
+type touchLayer struct {
+	touch.BasicLayer
+	events []touch.TouchEvent
+}
+
+func (l *touchLayer) StartTouch(e touch.TouchEvent)  { l.events = append(l.events, e) }
+func (l *touchLayer) UpdateTouch(e touch.TouchEvent) { l.events = append(l.events, e) }
+func (l *touchLayer) EndTouch(e touch.TouchEvent)    { l.events = append(l.events, e) }
+func (l *touchLayer) CancelTouch()                   {}
+
+func TestDebugServer(t *testing.T) {
+	clock := touch.NewManualClock(time.Unix(0, 0))
+	runloop := startHeadless(t, clock)
+	layer := &touchLayer{}
+	runloop.Do(func() error {
+		layer.Self = layer
+		layer.Background = color.White
+		layer.SetFrame(image.Rect(0, 0, 10, 10))
+		runloop.Window.AddChild(layer)
+		return nil
+	})
+	server := touch.NewDebugServer(runloop)
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	t.Run("Screen", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/screen.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		screen, err := png.Decode(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size := screen.Bounds().Size(); size != image.Pt(32, 32) {
+			t.Errorf("Expected a 32x32 screenshot, got %v", size)
+		}
+		if r, _, _, _ := screen.At(5, 5).RGBA(); r != 0xFFFF {
+			t.Errorf("Expected the white layer, got %v", screen.At(5, 5))
+		}
+	})
+	t.Run("Layers", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/layers")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var info touch.LayerInfo
+		if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+			t.Fatal(err)
+		}
+		if info.Type != "*touch.Window" || len(info.Children) != 1 || info.Children[0].Type != "*touch_test.touchLayer" {
+			t.Errorf("Unexpected layer tree %+v", info)
+		}
+	})
+	t.Run("Metrics", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var metrics touch.DebugMetrics
+		if err := json.NewDecoder(resp.Body).Decode(&metrics); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("Tap", func(t *testing.T) {
+		resp, err := http.Post(ts.URL+"/tap?x=5&y=6", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("Unexpected status %v", resp.Status)
+		}
+		events := func() (events []touch.TouchEvent) {
+			runloop.Do(func() error {
+				events = append(events, layer.events...)
+				return nil
+			})
+			return
+		}
+		if e := events(); len(e) != 1 || !e[0].Pressed || e[0].Point != image.Pt(5, 6) {
+			t.Fatalf("Expected a press at (5, 6), got %+v", e)
+		}
+		// Released by the runloop's clock
+		clock.Advance(touch.DebugTapDuration)
+		waitForTasks(runloop)
+		if e := events(); len(e) != 2 || e[1].Pressed {
+			t.Errorf("Expected the press to be released, got %+v", e)
+		}
+	})
+	t.Run("Bad Requests", func(t *testing.T) {
+		for _, test := range []struct {
+			method, path string
+			status       int
+		}{
+			{http.MethodGet, "/tap?x=1&y=1", http.StatusMethodNotAllowed},
+			{http.MethodPost, "/tap?x=a&y=1", http.StatusBadRequest},
+		} {
+			req, _ := http.NewRequest(test.method, ts.URL+test.path, nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.status {
+				t.Errorf("%s %s: expected %d, got %d", test.method, test.path, test.status, resp.StatusCode)
+			}
+		}
+	})
+	t.Run("ListenAndServe Restores OnFrame", func(t *testing.T) {
+		var frames int
+		runloop.Do(func() error {
+			runloop.Window.OnFrame = func(touch.FrameStats) { frames++ }
+			return nil
+		})
+		metrics := func() (metrics touch.DebugMetrics) {
+			resp, err := http.Get(ts.URL + "/metrics")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			json.NewDecoder(resp.Body).Decode(&metrics)
+			return
+		}
+		server.Addr = "127.0.0.1:0"
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- server.ListenAndServe(ctx) }()
+
+		// Redraw until the server records frames
+		deadline := time.Now().Add(time.Second)
+		for metrics().Frames.Frames == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("Timed out waiting for frames to be recorded")
+			}
+			runloop.Do(func() error {
+				runloop.Window.Invalidate()
+				return nil
+			})
+		}
+		if err := server.ListenAndServe(ctx); err == nil {
+			t.Error("Expected a second ListenAndServe to fail")
+		}
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+
+		waitForTasks(runloop)
+		recorded := metrics().Frames.Frames
+		runloop.Do(func() error {
+			frames = 0
+			runloop.Window.Invalidate()
+			return nil
+		})
+		waitForTasks(runloop)
+		runloop.Do(func() error {
+			if frames != 1 {
+				t.Errorf("Expected the original OnFrame to be called once, got %d", frames)
+			}
+			return nil
+		})
+		if n := metrics().Frames.Frames; n != recorded {
+			t.Errorf("Expected no frames to be recorded after serving, got %d more", n-recorded)
+		}
+	})
+}
@@ -12,6 +12,22 @@ type Display struct {
 
 	// Digitzer values for screen corners, and for weak / strong press
 	Calibration *TouchscreenCalibration
+
+	headless bool
+}
+
+// InitHeadless initializes a display with no output device or touchscreen.
+// Headless displays are useful for tests, and for running several windows
+// in one process.
+func (d *Display) InitHeadless(w, h int) {
+	*d = Display{
+		Size:     image.Point{w, h},
+		headless: true,
+	}
+}
+
+func (d *Display) IsHeadless() bool {
+	return d.headless
 }
 
 // Clear writes zeros to the framebuffer without performing
@@ -54,13 +54,16 @@ func (d *Display) Init(w, h, rotation int, framebufferFile string, calibration *
 }
 
 func (d *Display) Close() {
+	if d.headless {
+		return
+	}
 	d.Clear()
 	d.DeviceFile.Close()
 }
 
 func (d *Display) render(buf *image.RGBA) {
 	rect := buf.Rect
-	if rect.Empty() {
+	if rect.Empty() || d.headless {
 		// Nothing to draw
 		return
 	}
@@ -83,7 +86,3 @@ func (d *Display) render(buf *image.RGBA) {
 		}
 	}
 }
-
-func pixel565(r, g, b byte) (byte, byte) {
-	return ((g & 0b00011100) << 3) | b>>3, (r & 0b11111000) | g>>5
-}
@@ -0,0 +1,178 @@
+package touch
+
+import (
+	"fmt"
+	"image"
+	"sync"
+	"time"
+)
+
+// EPaperTransport sends converted pixels to a monochrome or grayscale panel.
+type EPaperTransport interface {
+	// Refresh writes pixels for rect to the panel, and updates that region of the
+	// display. Pixels are packed most significant bits first, with each row padded
+	// to a whole byte; 0 is black and the highest level is white. If full is set,
+	// rect covers the whole panel, which should be redrawn completely to clear ghosting.
+	Refresh(rect image.Rectangle, pixels []byte, full bool) error
+}
+
+// Defaults set by EPaperPanel.Init
+const (
+	DefaultEPaperRefreshInterval     = 500 * time.Millisecond
+	DefaultEPaperFullRefreshInterval = 20
+)
+
+// bayer4x4 holds the thresholds of a 4x4 ordered dither, in sixteenths.
+var bayer4x4 = [4][4]int{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// EPaperPanel converts frames for 1, 2, 4 or 8-bit grayscale panels, such
+// as e-paper displays and monochrome LCDs. Panels have no framebuffer, so the
+// window draws to a headless display of the panel's Size, and sends it frames
+// with AddSink.
+//
+// The regions updated by each frame are sent together as one partial refresh,
+// and the panel is periodically refreshed completely to clear ghosting.
+// As an e-paper refresh takes hundreds of milliseconds, EPaperPanel is a
+// SlowSink: the runloop draws at most one frame per RefreshInterval, so that
+// updates made in the meantime are combined into a single refresh.
+type EPaperPanel struct {
+	Transport EPaperTransport
+	Size      image.Point
+	// Bits per pixel: 1, 2, 4 or 8
+	Depth int
+	// Dither with an ordered pattern, rather than rounding each pixel to the
+	// nearest level. Unlike error diffusion, an ordered pattern depends only on
+	// each pixel's position, so partial refreshes don't leave seams.
+	Dither bool
+	// Minimum time between refreshes
+	RefreshInterval time.Duration
+	// Number of partial refreshes between full refreshes, or 0 to only refresh fully when needed.
+	FullRefreshInterval int
+
+	// Guards the refresh state, as RefreshFully may be called while the sink flushes
+	mu        sync.Mutex
+	partials  int
+	needsFull bool
+	buf       []byte
+}
+
+// Init configures the panel with a size and depth, dithering, and the default
+// refresh intervals. The first refresh is always a full refresh.
+func (p *EPaperPanel) Init(transport EPaperTransport, w, h, depth int) error {
+	if err := checkEPaperDepth(depth); err != nil {
+		return err
+	}
+	*p = EPaperPanel{
+		Transport:           transport,
+		Size:                image.Point{w, h},
+		Depth:               depth,
+		Dither:              true,
+		RefreshInterval:     DefaultEPaperRefreshInterval,
+		FullRefreshInterval: DefaultEPaperFullRefreshInterval,
+		needsFull:           true,
+	}
+	return nil
+}
+
+// checkEPaperDepth returns an error unless a whole number of pixels of depth fit in a byte.
+func checkEPaperDepth(depth int) error {
+	switch depth {
+	case 1, 2, 4, 8:
+		return nil
+	}
+	return fmt.Errorf("unsupported panel depth %d", depth)
+}
+
+// MinFrameInterval returns RefreshInterval, so the runloop waits for each refresh.
+func (p *EPaperPanel) MinFrameInterval() time.Duration {
+	return p.RefreshInterval
+}
+
+// RefreshFully causes the next update to refresh the whole panel.
+// It is safe to call from any goroutine.
+func (p *EPaperPanel) RefreshFully() {
+	p.mu.Lock()
+	p.needsFull = true
+	p.mu.Unlock()
+}
+
+// Flush converts the region covering rects, and sends it to the panel as one refresh.
+// It returns an error if Depth is not 1, 2, 4 or 8.
+func (p *EPaperPanel) Flush(buf *image.RGBA, rects []image.Rectangle) error {
+	// Depth may have been set without Init
+	if err := checkEPaperDepth(p.Depth); err != nil {
+		return err
+	}
+	screen := image.Rectangle{Max: p.Size}
+	var bounds image.Rectangle
+	for _, rect := range rects {
+		bounds = bounds.Union(rect.Intersect(screen))
+	}
+	if bounds.Empty() {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	full := p.needsFull || (p.FullRefreshInterval > 0 && p.partials >= p.FullRefreshInterval)
+	if full {
+		bounds = screen
+	}
+	// Panels address whole bytes of pixels
+	perByte := 8 / p.Depth
+	bounds.Min.X -= bounds.Min.X % perByte
+	if extra := bounds.Max.X % perByte; extra != 0 {
+		bounds.Max.X += perByte - extra
+	}
+
+	p.buf = p.pack(p.buf[:0], buf, bounds)
+	if err := p.Transport.Refresh(bounds, p.buf, full); err != nil {
+		return err
+	}
+	if full {
+		p.needsFull, p.partials = false, 0
+	} else {
+		p.partials++
+	}
+	return nil
+}
+
+// pack appends the pixels of buf in rect to dst, converted to gray levels.
+// Pixels outside buf are white.
+func (p *EPaperPanel) pack(dst []byte, buf *image.RGBA, rect image.Rectangle) []byte {
+	maxLevel := 1<<p.Depth - 1
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		var acc byte
+		shift := 8
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			level := maxLevel
+			if (image.Point{x, y}).In(buf.Rect) {
+				px := buf.Pix[buf.PixOffset(x, y):]
+				luma := (299*int(px[0]) + 587*int(px[1]) + 114*int(px[2])) / 1000
+				// Round to the nearest level, or offset by the pattern's threshold when dithering
+				bias := 127
+				if p.Dither {
+					bias = (2*bayer4x4[y&3][x&3] + 1) * 255 / 32
+				}
+				if level = (luma*maxLevel + bias) / 255; level > maxLevel {
+					level = maxLevel
+				}
+			}
+			shift -= p.Depth
+			acc |= byte(level) << shift
+			if shift == 0 {
+				dst = append(dst, acc)
+				acc, shift = 0, 8
+			}
+		}
+		if shift != 8 {
+			dst = append(dst, acc)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,133 @@
+package touch_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/bits"
+	"testing"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+type epaperRefresh struct {
+	rect   image.Rectangle
+	pixels []byte
+	full   bool
+}
+
+// recordingEPaper records the refreshes sent to a panel.
+type recordingEPaper struct {
+	refreshes []epaperRefresh
+}
+
+func (r *recordingEPaper) Refresh(rect image.Rectangle, pixels []byte, full bool) error {
+	r.refreshes = append(r.refreshes, epaperRefresh{rect, append([]byte(nil), pixels...), full})
+	return nil
+}
+
+func TestEPaperPanel(t *testing.T) {
+	transport := &recordingEPaper{}
+	panel := &touch.EPaperPanel{}
+	if err := panel.Init(transport, 32, 16, 1); err != nil {
+		t.Fatal(err)
+	}
+	panel.Dither = false
+	panel.FullRefreshInterval = 2
+
+	buf := image.NewRGBA(image.Rect(0, 0, 32, 16))
+	draw.Draw(buf, buf.Rect, image.White, image.Point{}, draw.Src)
+	// Black pixels at the left edges of the second and third bytes of row 3
+	buf.Set(8, 3, color.Black)
+	buf.Set(16, 3, color.Black)
+
+	t.Run("First Refresh Is Full", func(t *testing.T) {
+		panel.Flush(buf, []image.Rectangle{image.Rect(4, 4, 6, 6)})
+		if len(transport.refreshes) != 1 {
+			t.Fatalf("Expected one refresh, got %d", len(transport.refreshes))
+		}
+		r := transport.refreshes[0]
+		if !r.full || r.rect != buf.Rect || len(r.pixels) != 4*16 {
+			t.Errorf("Expected a full refresh of 64 bytes: %v, %v, %d bytes", r.full, r.rect, len(r.pixels))
+		}
+		if row := r.pixels[3*4 : 4*4]; row[0] != 0xFF || row[1] != 0x7F || row[2] != 0x7F || row[3] != 0xFF {
+			t.Errorf("Unexpected pixels in row 3: % X", row)
+		}
+	})
+	t.Run("Rects Are Batched And Aligned", func(t *testing.T) {
+		transport.refreshes = nil
+		panel.Flush(buf, []image.Rectangle{image.Rect(9, 3, 10, 4), image.Rect(14, 5, 17, 6)})
+		if len(transport.refreshes) != 1 {
+			t.Fatalf("Expected one refresh, got %d", len(transport.refreshes))
+		}
+		r := transport.refreshes[0]
+		if r.full || r.rect != image.Rect(8, 3, 24, 6) {
+			t.Errorf("Expected a partial refresh of (8,3)-(24,6): %v, %v", r.full, r.rect)
+		}
+		expected := []byte{0x7F, 0x7F, 0xFF, 0xFF, 0xFF, 0xFF}
+		if string(r.pixels) != string(expected) {
+			t.Errorf("Unexpected pixels: % X", r.pixels)
+		}
+	})
+	t.Run("Full Refresh After Interval", func(t *testing.T) {
+		transport.refreshes = nil
+		for i := 0; i < 2; i++ {
+			panel.Flush(buf, []image.Rectangle{image.Rect(0, 0, 1, 1)})
+		}
+		if len(transport.refreshes) != 2 || transport.refreshes[0].full || !transport.refreshes[1].full {
+			t.Errorf("Expected a partial refresh followed by a full refresh: %+v", transport.refreshes)
+		}
+	})
+	t.Run("RefreshFully Is Safe During Flush", func(t *testing.T) {
+		done := make(chan struct{})
+		go func() {
+			for i := 0; i < 100; i++ {
+				panel.RefreshFully()
+			}
+			close(done)
+		}()
+		for i := 0; i < 100; i++ {
+			panel.Flush(buf, []image.Rectangle{image.Rect(0, 0, 1, 1)})
+		}
+		<-done
+
+		transport.refreshes = nil
+		panel.RefreshFully()
+		panel.Flush(buf, []image.Rectangle{image.Rect(0, 0, 1, 1)})
+		if len(transport.refreshes) != 1 || !transport.refreshes[0].full {
+			t.Errorf("Expected a full refresh after RefreshFully: %+v", transport.refreshes)
+		}
+	})
+	t.Run("Dither Approximates Gray", func(t *testing.T) {
+		transport.refreshes = nil
+		panel.Dither = true
+		draw.Draw(buf, buf.Rect, image.NewUniform(color.Gray{0x80}), image.Point{}, draw.Src)
+		panel.Flush(buf, []image.Rectangle{image.Rect(0, 0, 8, 4)})
+
+		white := 0
+		for _, b := range transport.refreshes[0].pixels {
+			white += bits.OnesCount8(b)
+		}
+		if white != 16 {
+			t.Errorf("Expected half of 32 pixels to be white, got %d", white)
+		}
+	})
+}
+
+func TestEPaperDepth(t *testing.T) {
+	buf := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for _, depth := range []int{0, 3, 5, 16} {
+		transport := &recordingEPaper{}
+		if err := (&touch.EPaperPanel{}).Init(transport, 8, 8, depth); err == nil {
+			t.Errorf("Expected Init to reject a depth of %d", depth)
+		}
+		// Panels may also be configured without Init
+		panel := &touch.EPaperPanel{Transport: transport, Size: image.Pt(8, 8), Depth: depth}
+		if err := panel.Flush(buf, []image.Rectangle{buf.Rect}); err == nil {
+			t.Errorf("Expected Flush to reject a depth of %d", depth)
+		}
+		if len(transport.refreshes) != 0 {
+			t.Errorf("Expected no refresh at a depth of %d", depth)
+		}
+	}
+}
@@ -2,6 +2,9 @@ package touch
 
 import (
 	"image"
+	"os"
+	"sync"
+	"time"
 )
 
 type TouchEvent struct {
@@ -15,3 +18,132 @@ func (e TouchEvent) InRadius(e2 TouchEvent, r int) bool {
 	x, y := e.X-e2.X, e.Y-e2.Y
 	return x*x+y*y <= r*r
 }
+
+// Key identifies a key or button. Values match Linux input event codes.
+type Key uint16
+
+const (
+	KeyEscape   Key = 1
+	KeyTab      Key = 15
+	KeyEnter    Key = 28
+	KeySpace    Key = 57
+	KeyKPEnter  Key = 96
+	KeyUp       Key = 103
+	KeyLeft     Key = 105
+	KeyRight    Key = 106
+	KeyDown     Key = 108
+	KeyBack     Key = 158
+	KeyOK       Key = 0x160
+	KeySelect   Key = 0x161
+	KeyNext     Key = 0x197
+	KeyPrevious Key = 0x19c
+)
+
+type KeyEvent struct {
+	Key     Key
+	Pressed bool
+	// Repeat is set for auto-repeated presses of a held key
+	Repeat bool
+}
+
+// InputDeviceEvent reports an input device being connected or lost.
+type InputDeviceEvent struct {
+	Path      string
+	Connected bool
+	// Err describes why the device was lost or could not be opened
+	Err error
+}
+
+// EventStream collects events from input devices for delivery to a RunLoop.
+type EventStream struct {
+	Events chan TouchEvent
+	// Pointer carries events from mice, which are already in screen coordinates
+	Pointer chan TouchEvent
+	Keys    chan KeyEvent
+	Devices chan InputDeviceEvent
+
+	// Paths lists the input devices to read; On Linux, these are evdev nodes.
+	// If empty, /dev/input/event0 is used.
+	// Keys and rotary encoders are often separate devices from the touchscreen.
+	// Paths may be glob patterns, so that devices can be rediscovered under
+	// /dev/input/by-id or /dev/input/by-path after they are reconnected.
+	Paths []string
+	// RetryInterval is how often lost devices are polled for; Defaults to 1s.
+	RetryInterval time.Duration
+	// Grab requests exclusive access to each device while the RunLoop is active,
+	// so that consoles or X sessions on the same board don't receive its events.
+	Grab bool
+	dump bool
+
+	mu     sync.Mutex
+	open   []*os.File
+	closed bool
+	// The mouse cursor is shared between all pointing devices
+	cursor        image.Point
+	pointerBounds image.Rectangle
+}
+
+func (es *EventStream) Init() {
+	es.Events = make(chan TouchEvent, 100)
+	es.Pointer = make(chan TouchEvent, 100)
+	es.Keys = make(chan KeyEvent, 100)
+	es.Devices = make(chan InputDeviceEvent, 10)
+	if es.RetryInterval == 0 {
+		es.RetryInterval = time.Second
+	}
+	es.reopen()
+}
+
+// reopen lets a stream that was closed open devices again.
+func (es *EventStream) reopen() {
+	es.mu.Lock()
+	es.closed = false
+	es.mu.Unlock()
+}
+
+// sendSteps sends a press and release of KeyNext for each step of a scroll wheel
+// or rotary encoder, or of KeyPrevious for each negative step.
+func sendSteps(steps int, send func(KeyEvent)) {
+	key := KeyNext
+	if steps < 0 {
+		key, steps = KeyPrevious, -steps
+	}
+	for ; steps > 0; steps-- {
+		send(KeyEvent{Key: key, Pressed: true})
+		send(KeyEvent{Key: key})
+	}
+}
+
+// SetPointerBounds limits the mouse cursor to bounds. The cursor starts at the
+// center of the first bounds, and is moved to the nearest point within later bounds.
+func (es *EventStream) SetPointerBounds(bounds image.Rectangle) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.pointerBounds.Empty() {
+		es.cursor = bounds.Min.Add(bounds.Size().Div(2))
+	}
+	es.pointerBounds = bounds
+	es.cursor = es.clampPointer(es.cursor)
+}
+
+// movePointer offsets the cursor, clamped to the pointer bounds, and returns its position.
+func (es *EventStream) movePointer(dx, dy int) image.Point {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	es.cursor = es.clampPointer(es.cursor.Add(image.Point{dx, dy}))
+	return es.cursor
+}
+
+// clampPointer returns the point within the pointer bounds nearest to pt.
+func (es *EventStream) clampPointer(pt image.Point) image.Point {
+	clamp := func(v, min, max int) int {
+		if v < min {
+			return min
+		} else if v >= max {
+			return max - 1
+		}
+		return v
+	}
+	b := es.pointerBounds
+	return image.Point{clamp(pt.X, b.Min.X, b.Max.X), clamp(pt.Y, b.Min.Y, b.Max.Y)}
+}
@@ -1,25 +1,45 @@
 package touch
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"os"
+	"path/filepath"
 	"syscall"
+	"time"
+)
+
+// EVIOCGRAB is _IOW('E', 0x90, int)
+const EVIOCGRAB = 0x40044590
+
+// Access to evdev nodes, which tests replace with pipes
+var (
+	openDeviceFile = os.Open
+	grabDeviceFile = ioctlGrab
 )
 
 const (
+	EV_SYN = 0x00
+	EV_KEY = 0x01
+	EV_REL = 0x02
+	EV_ABS = 0x03
+
+	BTN_MISC  = 0x100
+	BTN_LEFT  = 0x110
 	BTN_TOUCH = 0x14a
 
 	ABS_X        = 0x00
 	ABS_Y        = 0x01
 	ABS_Z        = 0x02
 	ABS_PRESSURE = 0x18
-)
 
-type EventStream struct {
-	Events chan TouchEvent
-	dump   bool
-}
+	REL_X      = 0x00
+	REL_Y      = 0x01
+	REL_HWHEEL = 0x06
+	REL_DIAL   = 0x07
+	REL_WHEEL  = 0x08
+)
 
 type inputEvent struct {
 	Time  syscall.Timeval
@@ -28,19 +48,171 @@ type inputEvent struct {
 	Value int32
 }
 
-func (es *EventStream) Init() {
-	es.Events = make(chan TouchEvent, 100)
+// watchDevice reads events from the device at path until ctx is done.
+// When the device is lost or cannot be opened, it is polled for until it reappears.
+func (es *EventStream) watchDevice(ctx context.Context, path string) {
+	// Report the first failure to open, then only changes in connection state
+	connected := true
+	for {
+		deviceFile, err := es.openDevice(resolveDevicePath(path))
+		if deviceFile == nil && err == nil {
+			// The stream was closed
+			return
+		}
+		if err == nil {
+			connected = true
+			if !es.reportDevice(ctx, InputDeviceEvent{Path: path, Connected: true}) {
+				es.closeDevice(deviceFile)
+				return
+			}
+			err = es.inputReadLoop(ctx, deviceFile)
+			if !es.closeDevice(deviceFile) {
+				return
+			}
+		}
+		if connected {
+			connected = false
+			if !es.reportDevice(ctx, InputDeviceEvent{Path: path, Err: err}) {
+				return
+			}
+		}
+		retry := time.NewTimer(es.RetryInterval)
+		select {
+		case <-retry.C:
+		case <-ctx.Done():
+			retry.Stop()
+			return
+		}
+	}
+}
+
+// reportDevice sends a change in connection state to Devices.
+// Returns false if ctx is done before the event could be sent.
+func (es *EventStream) reportDevice(ctx context.Context, event InputDeviceEvent) bool {
+	select {
+	case es.Devices <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// openDevice opens and optionally grabs a device, tracking it for Close.
+// Returns nil and no error once the stream has been closed.
+func (es *EventStream) openDevice(path string) (*os.File, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.closed {
+		return nil, nil
+	}
+
+	deviceFile, err := openDeviceFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if es.Grab {
+		if err := grabDeviceFile(deviceFile, 1); err != nil {
+			deviceFile.Close()
+			return nil, fmt.Errorf("can't grab %s: %v", path, err)
+		}
+	}
+	es.open = append(es.open, deviceFile)
+	return deviceFile, nil
+}
+
+// closeDevice closes a device after reading fails.
+// Returns false if the stream was closed, and the device should not be reopened.
+func (es *EventStream) closeDevice(deviceFile *os.File) bool {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	for idx := range es.open {
+		if es.open[idx] == deviceFile {
+			es.open = append(es.open[:idx], es.open[idx+1:]...)
+			break
+		}
+	}
+	deviceFile.Close()
+	return !es.closed
+}
+
+// Close releases any grabbed devices and stops reading from all devices.
+func (es *EventStream) Close() {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	es.closed = true
+	for _, deviceFile := range es.open {
+		if es.Grab {
+			grabDeviceFile(deviceFile, 0)
+		}
+		// Closing the file interrupts the blocked read in inputReadLoop
+		deviceFile.Close()
+	}
+	es.open = nil
+}
+
+func ioctlGrab(deviceFile *os.File, grab uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, deviceFile.Fd(), EVIOCGRAB, grab)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// resolveDevicePath returns the first device matching a glob pattern, or path itself.
+func resolveDevicePath(path string) string {
+	if matches, _ := filepath.Glob(path); len(matches) > 0 {
+		return matches[0]
+	}
+	return path
+}
+
+// isKeyCode reports whether an EV_KEY code is a key, rather than a button of a mouse,
+// touchscreen or other device. Keyboard codes are below BTN_MISC; Of the codes above
+// the buttons, only those of remotes and rotary encoders with a press action are keys.
+func isKeyCode(code uint16) bool {
+	switch Key(code) {
+	case KeyOK, KeySelect, KeyNext, KeyPrevious:
+		return true
+	}
+	return code < BTN_MISC
 }
 
-func (es *EventStream) inputReadLoop(deviceFile *os.File) {
-	defer deviceFile.Close()
+// inputReadLoop reads events from deviceFile until a read fails, and returns the error.
+// Once ctx is done, events are dropped instead of waiting for the runloop to receive them.
+func (es *EventStream) inputReadLoop(ctx context.Context, deviceFile *os.File) error {
+	sendTouch := func(ch chan TouchEvent, event TouchEvent) {
+		select {
+		case ch <- event:
+		case <-ctx.Done():
+		}
+	}
+	sendKey := func(event KeyEvent) {
+		select {
+		case es.Keys <- event:
+		case <-ctx.Done():
+		}
+	}
 
-	var currentEvent TouchEvent
+	var currentEvent, pointerEvent TouchEvent
 	var e inputEvent
+	// Devices without a touchscreen must not emit touch events on sync
+	var touched bool
+	// Mouse motion is accumulated until the next sync
+	var moved bool
+	var dx, dy int
 
 	for {
 		if err := binary.Read(deviceFile, binary.LittleEndian, &e); err != nil {
-			return
+			// The lost device can't report its release, so end any touch in progress now
+			if currentEvent.Pressed {
+				currentEvent.Pressed = false
+				sendTouch(es.Events, currentEvent)
+			}
+			if pointerEvent.Pressed {
+				pointerEvent.Pressed = false
+				sendTouch(es.Pointer, pointerEvent)
+			}
+			return err
 		}
 
 		if e.Time.Sec == 0 {
@@ -52,14 +224,45 @@ func (es *EventStream) inputReadLoop(deviceFile *os.File) {
 		}
 
 		switch e.Type {
-		case 0:
-			es.Events <- currentEvent
-		case 1:
+		case EV_SYN:
+			if touched {
+				sendTouch(es.Events, currentEvent)
+				touched = false
+			}
+			if moved {
+				pointerEvent.Point = es.movePointer(dx, dy)
+				pointerEvent.Pressure = 0xFF
+				sendTouch(es.Pointer, pointerEvent)
+				moved, dx, dy = false, 0, 0
+			}
+		case EV_KEY:
 			// Button event
 			if e.Code == BTN_TOUCH {
 				currentEvent.Pressed = e.Value > 0
+				touched = true
+			} else if e.Code == BTN_LEFT {
+				pointerEvent.Pressed = e.Value > 0
+				moved = true
+			} else if isKeyCode(e.Code) {
+				sendKey(KeyEvent{Key: Key(e.Code), Pressed: e.Value > 0, Repeat: e.Value == 2})
+			}
+		case EV_REL:
+			// Mice move the cursor.
+			// Rotary encoders and wheels step the focus one control per detent.
+			switch e.Code {
+			case REL_X:
+				dx += int(e.Value)
+				moved = true
+			case REL_Y:
+				dy += int(e.Value)
+				moved = true
+			case REL_DIAL, REL_HWHEEL:
+				sendSteps(int(e.Value), sendKey)
+			case REL_WHEEL:
+				// Positive wheel values scroll up, toward the previous control
+				sendSteps(-int(e.Value), sendKey)
 			}
-		case 3:
+		case EV_ABS:
 			// State event
 			switch e.Code {
 			case ABS_X:
@@ -69,6 +272,7 @@ func (es *EventStream) inputReadLoop(deviceFile *os.File) {
 			case ABS_PRESSURE:
 				currentEvent.Pressure = int(e.Value)
 			}
+			touched = true
 		}
 	}
 }
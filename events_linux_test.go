@@ -0,0 +1,323 @@
+package touch_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jyopp/go-touch"
+)
+
+// fakeDevices opens pipes in place of evdev nodes, and records how they are grabbed.
+type fakeDevices struct {
+	mu      sync.Mutex
+	writers []*os.File
+	grabs   []uintptr
+	grabErr error
+}
+
+func useFakeDevices(t *testing.T) *fakeDevices {
+	f := &fakeDevices{}
+	restore := touch.SetDeviceAccess(f.open, f.grab)
+	t.Cleanup(func() {
+		restore()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, w := range f.writers {
+			w.Close()
+		}
+	})
+	return f
+}
+
+func (f *fakeDevices) open(path string) (*os.File, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writers = append(f.writers, w)
+	return r, nil
+}
+
+func (f *fakeDevices) grab(deviceFile *os.File, grab uintptr) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.grabs = append(f.grabs, grab)
+	return f.grabErr
+}
+
+// send writes events to the most recently opened device.
+func (f *fakeDevices) send(t *testing.T, events ...[]byte) {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, event := range events {
+		if _, err := f.writers[len(f.writers)-1].Write(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// unplug ends the most recently opened device, as if it was disconnected.
+func (f *fakeDevices) unplug() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writers[len(f.writers)-1].Close()
+}
+
+func (f *fakeDevices) grabbed() []uintptr {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]uintptr(nil), f.grabs...)
+}
+
+// nextDeviceEvent waits for the stream to report a change in connection state.
+func nextDeviceEvent(t *testing.T, es *touch.EventStream) touch.InputDeviceEvent {
+	t.Helper()
+	select {
+	case event := <-es.Devices:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("No device event was reported")
+		return touch.InputDeviceEvent{}
+	}
+}
+
+func TestGrab(t *testing.T) {
+	// watch reads a fake device until the test ends, returning a channel closed when it stops.
+	watch := func(t *testing.T, es *touch.EventStream) <-chan struct{} {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			es.WatchDevice(ctx, "/dev/input/fake")
+			close(done)
+		}()
+		t.Cleanup(func() {
+			cancel()
+			<-done
+		})
+		return done
+	}
+	expectGrabs := func(t *testing.T, devices *fakeDevices, want ...uintptr) {
+		t.Helper()
+		grabs := devices.grabbed()
+		ok := len(grabs) == len(want)
+		for idx := 0; ok && idx < len(want); idx++ {
+			ok = grabs[idx] == want[idx]
+		}
+		if !ok {
+			t.Errorf("Expected grabs %v, got %v", want, grabs)
+		}
+	}
+
+	t.Run("Grab On Open And Reconnect", func(t *testing.T) {
+		devices := useFakeDevices(t)
+		es := &touch.EventStream{Grab: true, RetryInterval: time.Millisecond}
+		es.Init()
+		done := watch(t, es)
+
+		if event := nextDeviceEvent(t, es); !event.Connected {
+			t.Fatalf("Expected the device to connect, got %+v", event)
+		}
+		expectGrabs(t, devices, 1)
+
+		devices.unplug()
+		if event := nextDeviceEvent(t, es); event.Connected || event.Err == nil {
+			t.Fatalf("Expected the device to be lost, got %+v", event)
+		}
+		if event := nextDeviceEvent(t, es); !event.Connected {
+			t.Fatalf("Expected the device to reconnect, got %+v", event)
+		}
+		expectGrabs(t, devices, 1, 1)
+
+		// Closing the stream releases the grab, and stops reading
+		es.Close()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Device was still read after Close")
+		}
+		expectGrabs(t, devices, 1, 1, 0)
+	})
+	t.Run("No Grab By Default", func(t *testing.T) {
+		devices := useFakeDevices(t)
+		es := &touch.EventStream{RetryInterval: time.Millisecond}
+		es.Init()
+		watch(t, es)
+
+		if event := nextDeviceEvent(t, es); !event.Connected {
+			t.Fatalf("Expected the device to connect, got %+v", event)
+		}
+		es.Close()
+		expectGrabs(t, devices)
+	})
+	t.Run("Grab Failure Is Reported", func(t *testing.T) {
+		devices := useFakeDevices(t)
+		devices.grabErr = errors.New("busy")
+		es := &touch.EventStream{Grab: true, RetryInterval: time.Hour}
+		es.Init()
+		watch(t, es)
+
+		if event := nextDeviceEvent(t, es); event.Connected || event.Err == nil {
+			t.Fatalf("Expected the grab to fail, got %+v", event)
+		}
+	})
+}
+
+func TestWatchDevice(t *testing.T) {
+	t.Run("Stops When Cancelled", func(t *testing.T) {
+		var es touch.EventStream
+		es.RetryInterval = time.Millisecond
+		es.Init()
+		// Nothing drains Devices, so reporting the missing device must not block
+		for i := 0; i < cap(es.Devices); i++ {
+			es.Devices <- touch.InputDeviceEvent{}
+		}
+
+		path := filepath.Join(t.TempDir(), "event0")
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			es.WatchDevice(ctx, path)
+			close(done)
+		}()
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("watchDevice did not return after its context was cancelled")
+		}
+	})
+
+	t.Run("Stops Retrying When Cancelled", func(t *testing.T) {
+		var es touch.EventStream
+		es.RetryInterval = time.Hour
+		es.Init()
+
+		path := filepath.Join(t.TempDir(), "event0")
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			es.WatchDevice(ctx, path)
+			close(done)
+		}()
+		select {
+		case event := <-es.Devices:
+			if event.Connected || event.Err == nil {
+				t.Errorf("Expected a failure to open the device, got %+v", event)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("No device event was reported")
+		}
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("watchDevice kept waiting to retry after its context was cancelled")
+		}
+	})
+}
+
+func TestIsKeyCode(t *testing.T) {
+	tests := []struct {
+		name string
+		code uint16
+		key  bool
+	}{
+		{"Escape", uint16(touch.KeyEscape), true},
+		{"Enter", uint16(touch.KeyEnter), true},
+		{"Back", uint16(touch.KeyBack), true},
+		{"OK", uint16(touch.KeyOK), true},
+		{"Select", uint16(touch.KeySelect), true},
+		{"Next", uint16(touch.KeyNext), true},
+		{"Previous", uint16(touch.KeyPrevious), true},
+		{"Right Mouse Button", 0x111, false},
+		{"Middle Mouse Button", 0x112, false},
+		{"Side Mouse Button", 0x113, false},
+		{"Finger Tool", 0x145, false},
+		{"Double Tap Tool", 0x14d, false},
+		{"Stylus Button", 0x14b, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if key := touch.IsKeyCode(test.code); key != test.key {
+				t.Errorf("Expected %v for code %#x, got %v", test.key, test.code, key)
+			}
+		})
+	}
+}
+
+func TestRunWatchesDevices(t *testing.T) {
+	useFakeDevices(t)
+	runloop := newHeadless(nil)
+	runloop.Input.Paths = []string{"/dev/input/fake"}
+	connected := make(chan bool, 10)
+	runloop.InputDeviceChanged = func(event touch.InputDeviceEvent) {
+		connected <- event.Connected
+	}
+
+	// Each Run reopens the devices closed when the last one returned
+	for run := 1; run <= 2; run++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			runloop.Run(ctx)
+			close(done)
+		}()
+		select {
+		case ok := <-connected:
+			if !ok {
+				t.Errorf("Run %d: expected the device to connect", run)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("Run %d: the device was not opened", run)
+		}
+		cancel()
+		<-done
+	}
+}
+
+func TestLostTouch(t *testing.T) {
+	const evKey, evSyn, btnTouch = 0x01, 0x00, 0x14a
+	devices := useFakeDevices(t)
+	es := &touch.EventStream{RetryInterval: time.Hour}
+	es.Init()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		es.WatchDevice(ctx, "/dev/input/fake")
+		close(done)
+	}()
+	if event := nextDeviceEvent(t, es); !event.Connected {
+		t.Fatalf("Expected the device to connect, got %+v", event)
+	}
+
+	devices.send(t, touch.EncodeInputEvent(evKey, btnTouch, 1), touch.EncodeInputEvent(evSyn, 0, 0))
+	select {
+	case event := <-es.Events:
+		if !event.Pressed {
+			t.Fatalf("Expected a press, got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No touch was read")
+	}
+
+	// Once the runloop stops, nothing receives the release of the lost touch
+	for len(es.Events) < cap(es.Events) {
+		es.Events <- touch.TouchEvent{}
+	}
+	cancel()
+	es.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Reading the lost device blocked after its context was cancelled")
+	}
+}
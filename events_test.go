@@ -0,0 +1,120 @@
+package touch_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+func TestPointer(t *testing.T) {
+	bounds := image.Rect(10, 20, 110, 220)
+	moves := []struct {
+		name   string
+		dx, dy int
+		want   image.Point
+	}{
+		{"Starts At Center", 0, 0, image.Pt(60, 120)},
+		{"Moves Within Bounds", -5, 7, image.Pt(55, 127)},
+		{"Clamped At Left", -1000, 0, image.Pt(10, 120)},
+		{"Clamped At Right", 1000, 0, image.Pt(109, 120)},
+		{"Clamped At Top", 0, -1000, image.Pt(60, 20)},
+		{"Clamped At Bottom", 0, 1000, image.Pt(60, 219)},
+		{"Clamped At Corner", 1000, -1000, image.Pt(109, 20)},
+	}
+	for _, test := range moves {
+		t.Run(test.name, func(t *testing.T) {
+			var es touch.EventStream
+			es.SetPointerBounds(bounds)
+			if pt := es.MovePointer(test.dx, test.dy); pt != test.want {
+				t.Errorf("Expected the pointer at %v, got %v", test.want, pt)
+			}
+		})
+	}
+
+	boundsChanges := []struct {
+		name   string
+		dx, dy int
+		bounds image.Rectangle
+		want   image.Point
+	}{
+		{"Pointer Inside New Bounds Stays", -40, -90, image.Rect(0, 0, 50, 50), image.Pt(20, 30)},
+		{"Pointer Right Of New Bounds", 40, 0, image.Rect(0, 0, 50, 200), image.Pt(49, 120)},
+		{"Pointer Below New Bounds", 0, 90, image.Rect(0, 0, 200, 100), image.Pt(60, 99)},
+		{"Pointer Before New Bounds", 0, 0, image.Rect(80, 150, 100, 160), image.Pt(80, 150)},
+	}
+	for _, test := range boundsChanges {
+		t.Run(test.name, func(t *testing.T) {
+			var es touch.EventStream
+			es.SetPointerBounds(bounds)
+			es.MovePointer(test.dx, test.dy)
+			es.SetPointerBounds(test.bounds)
+			if pt := es.MovePointer(0, 0); pt != test.want {
+				t.Errorf("Expected the pointer at %v, got %v", test.want, pt)
+			}
+			// Later motion is limited to the new bounds
+			if pt := es.MovePointer(1000, 1000); pt != test.bounds.Max.Sub(image.Pt(1, 1)) {
+				t.Errorf("Expected the pointer clamped to %v, got %v", test.bounds, pt)
+			}
+		})
+	}
+}
+
+func TestCursorOverlay(t *testing.T) {
+	blue := color.RGBA{0, 0, 0xFF, 0xFF}
+	white := color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	runloop := startHeadless(t, nil)
+	runloop.Do(func() error {
+		runloop.Window.Background = blue
+		runloop.Window.Invalidate()
+		return nil
+	})
+	// movePointer waits until the runloop has received the pointer event, and drawn the frame it requests.
+	movePointer := func(pt image.Point) {
+		runloop.Input.Pointer <- touch.TouchEvent{Point: pt, Pressure: 0xFF}
+		for pending := true; pending; {
+			runloop.Do(func() error {
+				pending = len(runloop.Input.Pointer) > 0 || runloop.Window.RedrawPending()
+				return nil
+			})
+		}
+	}
+	// The arrow's fill is below and right of its tip
+	fillAt := func(pt image.Point) image.Point {
+		return pt.Add(image.Pt(1, 3))
+	}
+
+	t.Run("Hidden By Default", func(t *testing.T) {
+		movePointer(image.Pt(4, 4))
+		if c := pixelAt(runloop, 5, 7); c != blue {
+			t.Errorf("Expected no cursor, got %v", c)
+		}
+	})
+	t.Run("Overlay Tracks Pointer", func(t *testing.T) {
+		runloop.Do(func() error {
+			runloop.Window.ShowCursor = true
+			return nil
+		})
+		for _, pt := range []image.Point{image.Pt(4, 4), image.Pt(20, 10)} {
+			movePointer(pt)
+			if c := pixelAt(runloop, fillAt(pt).X, fillAt(pt).Y); c != white {
+				t.Errorf("Expected the cursor at %v, got %v", pt, c)
+			}
+		}
+		// The cursor is no longer drawn where it was
+		if c := pixelAt(runloop, 5, 7); c != blue {
+			t.Errorf("Expected the cursor's old position to be redrawn, got %v", c)
+		}
+	})
+	t.Run("Overlay Is Removed When Hidden", func(t *testing.T) {
+		runloop.Do(func() error {
+			runloop.Window.ShowCursor = false
+			return nil
+		})
+		movePointer(image.Pt(4, 4))
+		if c := pixelAt(runloop, 21, 13); c != blue {
+			t.Errorf("Expected the cursor to be removed, got %v", c)
+		}
+	})
+}
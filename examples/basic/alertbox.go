@@ -66,7 +66,7 @@ func (alert *AlertBox) AddButton(label string, action func()) *touch.Button {
 	button.Colors.Normal.Text = AlertBoxConfig.ButtonFont.Color
 	button.Colors.Normal.Background = AlertBoxConfig.ButtonBackground
 	button.Actions[touch.ControlTapped] = func(button *touch.Button) {
-		alert.RemoveFromParent()
+		touch.DismissChild(alert, touch.Transition{Style: touch.TransitionFade})
 		action()
 	}
 
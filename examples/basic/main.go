@@ -5,9 +5,13 @@ import (
 	"flag"
 	"fmt"
 	"image/color"
+	"log"
 	"os"
 	"os/signal"
 	"runtime/pprof"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/jyopp/go-touch"
 )
@@ -61,7 +65,7 @@ func showSimpleAlert(message string, buttonCount int, done func()) {
 	}
 
 	// Alert will size itself and lay out when added to parent
-	window.AddChild(alert)
+	touch.PresentChild(window, alert, touch.Transition{Style: touch.TransitionScale})
 }
 
 func buildUI() {
@@ -124,6 +128,19 @@ var (
 func main() {
 	rotationAngle := flag.Int("rotation", 0, "Rotation of the display")
 	cpuprofile := flag.String("cpuprofile", "", "Enable CPU Profiling to the given file")
+	keyDevices := flag.String("keys", "", "Comma-separated evdev nodes for keys or rotary encoders")
+	grabInput := flag.Bool("grab", false, "Grab input devices so other programs don't receive their events")
+	showCursor := flag.Bool("cursor", false, "Draw a cursor when a mouse is used")
+	maxFPS := flag.Int("fps", 0, "Maximum display updates per second, or 0 for no limit")
+	logFrames := flag.Bool("log-frames", false, "Log frames that take longer than 1ms to update")
+	debugOverlay := flag.Bool("debug-overlay", false, "Flash updated regions and show frame times")
+	debugAddr := flag.String("debug-addr", "", "Serve screenshots, layers and tap injection over HTTP at this address")
+	simulatorAddr := flag.String("simulator", "", "Show the UI in a web browser at this address, instead of on the framebuffer")
+	useX11 := flag.Bool("x11", false, "Show the UI in an X11 window on $DISPLAY, instead of on the framebuffer")
+	useTerminal := flag.Bool("terminal", false, "Show the UI in this terminal, instead of on the framebuffer")
+	vncAddr := flag.String("vnc", "", "Serve the UI to VNC clients at this address")
+	headless := flag.Bool("headless", false, "Don't draw to the framebuffer, e.g. when using -vnc")
+	recordPath := flag.String("record", "", "Record the UI to an animated GIF, or to a directory of PNG frames")
 	flag.Parse()
 
 	if *cpuprofile != "" {
@@ -139,17 +156,140 @@ func main() {
 	}
 
 	display := &touch.Display{}
-	display.Init(320, 480, *rotationAngle, "/dev/fb1", &touchCalibration)
+	var sinks []touch.DisplaySink
+	var simulator *touch.Simulator
+	var x11Done <-chan struct{}
+	if *simulatorAddr != "" {
+		display.InitHeadless(320, 480)
+		simulator = touch.NewSimulator(&touch.MainRunLoop)
+		simulator.Addr = *simulatorAddr
+		sinks = append(sinks, simulator)
+	} else if *useX11 {
+		display.InitHeadless(320, 480)
+		xwin, err := touch.OpenX11Window(&touch.MainRunLoop, "", display.Size)
+		if err != nil {
+			panic(err)
+		}
+		defer xwin.Close()
+		x11Done = xwin.Done()
+		sinks = append(sinks, xwin)
+	} else if *useTerminal {
+		display.InitHeadless(320, 480)
+		term, err := touch.OpenTerminal(&touch.MainRunLoop, display.Size)
+		if err != nil {
+			panic(err)
+		}
+		defer term.Close()
+		sinks = append(sinks, term)
+	} else if *headless {
+		display.InitHeadless(320, 480)
+	} else {
+		display.Init(320, 480, *rotationAngle, "/dev/fb1", &touchCalibration)
+	}
 	defer display.Close()
 
+	var vnc *touch.VNCServer
+	if *vncAddr != "" {
+		vnc = touch.NewVNCServer(&touch.MainRunLoop, display.Size)
+		vnc.Addr = *vncAddr
+		sinks = append(sinks, vnc)
+	}
+
+	var recorder *touch.Recorder
+	if strings.HasSuffix(*recordPath, ".gif") {
+		file, err := os.Create(*recordPath)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+		recorder = touch.NewGIFRecorder(file)
+	} else if *recordPath != "" {
+		var err error
+		if recorder, err = touch.NewPNGRecorder(*recordPath); err != nil {
+			panic(err)
+		}
+	}
+	if recorder != nil {
+		sinks = append(sinks, recorder)
+	}
+
 	window.Init(display)
+	for _, sink := range sinks {
+		window.AddSink(sink)
+	}
 	window.Radius = 9
+	window.ShowCursor = *showCursor
+	window.SetDebugOverlay(*debugOverlay)
+	if *logFrames {
+		window.OnFrame = touch.LogFrameStats(log.New(os.Stderr, "", log.LstdFlags), time.Millisecond)
+	}
 
 	signalCtx, signalCleanup := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer signalCleanup()
+	if x11Done != nil {
+		// Quit when the X11 window is closed
+		var cancel context.CancelFunc
+		signalCtx, cancel = context.WithCancel(signalCtx)
+		go func() {
+			<-x11Done
+			cancel()
+		}()
+	}
+
+	touch.MainRunLoop.MaxFPS = *maxFPS
+	touch.MainRunLoop.ImmediateFirstFrame = true
+	touch.MainRunLoop.Input.Grab = *grabInput
+	if *keyDevices != "" {
+		touch.MainRunLoop.Input.Paths = append([]string{"/dev/input/event0"}, strings.Split(*keyDevices, ",")...)
+	}
 
 	// Initialize runloop before UI so it's OK to send to its channels.
 	touch.MainRunLoop.Init(window)
 	buildUI()
+	touch.MainRunLoop.InputDeviceChanged = func(ev touch.InputDeviceEvent) {
+		if ev.Connected {
+			statusText.SetText("Connected " + ev.Path)
+		} else {
+			statusText.SetText("Lost " + ev.Path)
+		}
+	}
+	if simulator != nil {
+		go func() {
+			if err := simulator.ListenAndServe(signalCtx); err != nil {
+				log.Printf("Simulator: %v", err)
+			}
+		}()
+	}
+	if vnc != nil {
+		go func() {
+			if err := vnc.ListenAndServe(signalCtx); err != nil {
+				log.Printf("VNC: %v", err)
+			}
+		}()
+	}
+	if *debugAddr != "" {
+		debugServer := touch.NewDebugServer(&touch.MainRunLoop)
+		debugServer.Addr = *debugAddr
+		go func() {
+			if err := debugServer.ListenAndServe(signalCtx); err != nil {
+				log.Printf("Debug server: %v", err)
+			}
+		}()
+	}
+	// `kill -USR1` prints the layer tree, for debugging layout; `kill -USR2` prints it as JSON.
+	touch.MainRunLoop.DumpLayersOnSignal(signalCtx, func(info touch.LayerInfo) {
+		info.WriteText(os.Stderr)
+	}, syscall.SIGUSR1)
+	touch.MainRunLoop.DumpLayersOnSignal(signalCtx, func(info touch.LayerInfo) {
+		info.WriteJSON(os.Stderr)
+	}, syscall.SIGUSR2)
 	touch.MainRunLoop.Run(signalCtx)
+
+	if recorder != nil {
+		// Wait for frames already drawn to be recorded
+		<-window.RemoveSink(recorder)
+		if err := recorder.Close(); err != nil {
+			log.Printf("Recording: %v", err)
+		}
+	}
 }
@@ -0,0 +1,32 @@
+package touch
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+)
+
+var IsKeyCode = isKeyCode
+
+// WatchDevice reads the device at path until ctx is done, as Run does for each of Paths.
+func (es *EventStream) WatchDevice(ctx context.Context, path string) {
+	es.watchDevice(ctx, path)
+}
+
+// SetDeviceAccess replaces how devices are opened and grabbed, until restore is called.
+func SetDeviceAccess(open func(path string) (*os.File, error), grab func(deviceFile *os.File, grab uintptr) error) (restore func()) {
+	openDeviceFile, grabDeviceFile = open, grab
+	return func() {
+		openDeviceFile, grabDeviceFile = os.Open, ioctlGrab
+	}
+}
+
+// EncodeInputEvent returns an event as it is read from an evdev node.
+func EncodeInputEvent(typ, code uint16, value int32) []byte {
+	var buf bytes.Buffer
+	e := inputEvent{Type: typ, Code: code, Value: value}
+	e.Time.Sec = 1
+	binary.Write(&buf, binary.LittleEndian, &e)
+	return buf.Bytes()
+}
@@ -0,0 +1,98 @@
+package touch
+
+import (
+	"bufio"
+	"image"
+	"net"
+	"net/http"
+	"os"
+)
+
+// Unexported functions, exported for the external tests in package touch_test.
+var (
+	LerpColor  = lerpColor
+	Percentile = percentile
+)
+
+// SinkFailed reports a failed sink, as its worker does when Flush returns an error.
+func (w *Window) SinkFailed(sink DisplaySink, err error) {
+	w.sinkFailed(sink, err)
+}
+
+// RedrawPending reports whether the window has requested a frame that the runloop hasn't yet scheduled.
+func (w *Window) RedrawPending() bool {
+	return len(w.redrawCh) > 0
+}
+
+// PendingTimers returns the number of timers waiting for the clock to advance.
+func (c *ManualClock) PendingTimers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// MovePointer moves the mouse cursor as relative motion does, and returns its position.
+func (es *EventStream) MovePointer(dx, dy int) image.Point {
+	return es.movePointer(dx, dy)
+}
+
+// NewTestTerminal returns a Terminal that delivers input to runloop, without opening a terminal.
+func NewTestTerminal(runloop *RunLoop, scale int) *Terminal {
+	return &Terminal{runloop: runloop, scale: scale}
+}
+
+// NewTestTerminalOutput returns a Terminal of the given size that draws to out, which is
+// not a terminal, and so has the default size and no settings to restore.
+func NewTestTerminalOutput(out *os.File, size image.Point) *Terminal {
+	t := &Terminal{
+		in:      out,
+		out:     out,
+		resized: make(chan os.Signal, 1),
+		mirror:  image.NewRGBA(image.Rectangle{Max: size}),
+	}
+	t.w = bufio.NewWriter(out)
+	t.resize()
+	return t
+}
+
+func (t *Terminal) ParseInput(input []byte, idle bool) []byte {
+	return t.parseInput(input, idle)
+}
+
+func (s *VNCServer) ServeConn(conn net.Conn) {
+	s.serveConn(conn)
+}
+
+type WSConn = wsConn
+
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request) (*WSConn, error) {
+	return upgradeWebSocket(w, r)
+}
+
+// NewTestX11Window returns an X11Window that reads events from conn, without an X server.
+func NewTestX11Window(runloop *RunLoop, conn net.Conn, size image.Point) *X11Window {
+	return &X11Window{
+		runloop:  runloop,
+		conn:     conn,
+		done:     make(chan struct{}),
+		mirror:   image.NewRGBA(image.Rectangle{Max: size}),
+		keysDown: map[byte]bool{},
+	}
+}
+
+func (x *X11Window) ParseSetup(info []byte) error {
+	return x.parseSetup(info)
+}
+
+// SetupResult returns the state read from the setup reply.
+func (x *X11Window) SetupResult() (root, black, window, gc uint32, maxRequest int) {
+	return x.root, x.black, x.window, x.gc, x.maxRequest
+}
+
+func (x *X11Window) EventLoop() {
+	x.eventLoop()
+}
+
+func (x *X11Window) SetDeleteAtoms(protocols, delete uint32) {
+	x.protocolsAtom, x.deleteAtom = protocols, delete
+}
@@ -0,0 +1,125 @@
+package touch
+
+// Focusable is implemented by layers that can hold the keyboard focus.
+type Focusable interface {
+	Layer
+	CanFocus() bool
+	SetFocused(bool)
+}
+
+// LayerKeyDelegate is implemented by layers that respond to keys while focused.
+// HandleKey returns true if the event was consumed.
+type LayerKeyDelegate interface {
+	HandleKey(KeyEvent) bool
+}
+
+// Focused returns the layer holding the keyboard focus, or nil.
+func (w *Window) Focused() Focusable {
+	return w.focused
+}
+
+// SetFocus moves the keyboard focus to f. A nil value clears the focus.
+func (w *Window) SetFocus(f Focusable) {
+	if f == w.focused {
+		return
+	}
+	if w.focused != nil {
+		w.focused.SetFocused(false)
+	}
+	w.focused = f
+	if f != nil {
+		f.SetFocused(true)
+	}
+}
+
+// FocusNext moves the focus to the next focusable layer, wrapping around.
+func (w *Window) FocusNext() {
+	w.moveFocus(1)
+}
+
+// FocusPrevious moves the focus to the previous focusable layer, wrapping around.
+func (w *Window) FocusPrevious() {
+	w.moveFocus(-1)
+}
+
+// HandleKey delivers a key event to the focused layer.
+// Unhandled arrow, tab, and encoder keys move the focus.
+func (w *Window) HandleKey(event KeyEvent) {
+	// Drop the focus from layers that were removed or disabled
+	if w.focused != nil && focusIndex(w.focusScope(), w.focused) < 0 {
+		w.SetFocus(nil)
+	}
+	if del, ok := w.focused.(LayerKeyDelegate); ok && del.HandleKey(event) {
+		return
+	}
+	if !event.Pressed {
+		return
+	}
+	switch event.Key {
+	case KeyNext, KeyDown, KeyRight, KeyTab:
+		w.FocusNext()
+	case KeyPrevious, KeyUp, KeyLeft:
+		w.FocusPrevious()
+	case KeyEscape, KeyBack:
+		w.SetFocus(nil)
+	}
+}
+
+func (w *Window) moveFocus(step int) {
+	scope := w.focusScope()
+	if len(scope) == 0 {
+		w.SetFocus(nil)
+		return
+	}
+	idx := focusIndex(scope, w.focused)
+	if idx < 0 {
+		// Enter the scope from whichever end is in the direction of travel
+		if idx = 0; step < 0 {
+			idx = len(scope) - 1
+		}
+	} else {
+		idx = (idx + step + len(scope)) % len(scope)
+	}
+	w.SetFocus(scope[idx])
+}
+
+// visibility is implemented by layers that can be hidden, such as BasicLayer.
+type visibility interface {
+	Visible() bool
+}
+
+// focusScope returns the focusable layers of the front-most child that has any.
+// This keeps the focus inside modal layers, such as alerts, added over the UI.
+func (w *Window) focusScope() []Focusable {
+	children := w.Children()
+	for idx := len(children); idx > 0; idx-- {
+		if scope := appendFocusable(nil, children[idx-1]); len(scope) > 0 {
+			return scope
+		}
+	}
+	return nil
+}
+
+// appendFocusable appends focusable layers in the subtree at layer, in drawing order.
+// Hidden and fully transparent subtrees are skipped.
+func appendFocusable(list []Focusable, layer Layer) []Focusable {
+	if v, ok := layer.(visibility); ok && !v.Visible() {
+		return list
+	}
+	if f, ok := layer.(Focusable); ok && f.CanFocus() {
+		list = append(list, f)
+	}
+	for _, child := range layer.Children() {
+		list = appendFocusable(list, child)
+	}
+	return list
+}
+
+func focusIndex(scope []Focusable, f Focusable) int {
+	for idx := range scope {
+		if scope[idx] == f {
+			return idx
+		}
+	}
+	return -1
+}
@@ -0,0 +1,208 @@
+package touch_test
+
+import (
+	"image"
+	"testing"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+// actionControl is a control that records the actions it handles.
+type actionControl struct {
+	touch.ControlLayer
+	actions []touch.ControlAction
+}
+
+func newActionControl(frame image.Rectangle) *actionControl {
+	c := &actionControl{}
+	c.Self = c
+	c.SetFrame(frame)
+	return c
+}
+
+func (c *actionControl) StateDidChange() {}
+
+func (c *actionControl) HandleAction(action touch.ControlAction) {
+	c.actions = append(c.actions, action)
+}
+
+func (c *actionControl) ShouldHandleAction(touch.ControlAction) bool {
+	return true
+}
+
+// pressKey injects a press and release of key.
+func pressKey(runloop *touch.RunLoop, key touch.Key) {
+	runloop.InjectKey(touch.KeyEvent{Key: key, Pressed: true})
+	runloop.InjectKey(touch.KeyEvent{Key: key})
+}
+
+// focused returns the layer holding the focus of the runloop's window.
+func focused(runloop *touch.RunLoop) (f touch.Focusable) {
+	runloop.Do(func() error {
+		f = runloop.Window.Focused()
+		return nil
+	})
+	return
+}
+
+func TestFocus(t *testing.T) {
+	// startControls runs a window containing a screen of three controls, in a row.
+	startControls := func(t *testing.T) (*touch.RunLoop, []*actionControl) {
+		runloop := startHeadless(t, nil)
+		controls := []*actionControl{
+			newActionControl(image.Rect(0, 0, 10, 10)),
+			newActionControl(image.Rect(10, 0, 20, 10)),
+			newActionControl(image.Rect(20, 0, 30, 10)),
+		}
+		screen := &touch.BasicLayer{}
+		runloop.Do(func() error {
+			screen.Self = screen
+			screen.SetFrame(runloop.Window.Rectangle)
+			for _, c := range controls {
+				screen.AddChild(c)
+			}
+			runloop.Window.AddChild(screen)
+			return nil
+		})
+		return runloop, controls
+	}
+
+	navigation := []struct {
+		name string
+		keys []touch.Key
+		// The index of the control focused after each key, or -1 for none
+		focus []int
+	}{
+		{"Next Wraps Around", []touch.Key{touch.KeyNext, touch.KeyNext, touch.KeyNext, touch.KeyNext}, []int{0, 1, 2, 0}},
+		{"Previous Wraps Around", []touch.Key{touch.KeyPrevious, touch.KeyPrevious, touch.KeyPrevious, touch.KeyPrevious}, []int{2, 1, 0, 2}},
+		{"Arrows And Tab Move Focus", []touch.Key{touch.KeyDown, touch.KeyRight, touch.KeyTab, touch.KeyUp, touch.KeyLeft}, []int{0, 1, 2, 1, 0}},
+		{"Escape Clears Focus", []touch.Key{touch.KeyNext, touch.KeyNext, touch.KeyEscape, touch.KeyNext}, []int{0, 1, -1, 0}},
+		{"Back Clears Focus", []touch.Key{touch.KeyPrevious, touch.KeyBack}, []int{2, -1}},
+	}
+	for _, test := range navigation {
+		t.Run(test.name, func(t *testing.T) {
+			runloop, controls := startControls(t)
+			for idx, key := range test.keys {
+				pressKey(runloop, key)
+				var want touch.Focusable
+				if test.focus[idx] >= 0 {
+					want = controls[test.focus[idx]]
+				}
+				if f := focused(runloop); f != want {
+					t.Fatalf("After key %d (%#x), expected control %d to be focused", idx, key, test.focus[idx])
+				}
+			}
+		})
+	}
+
+	t.Run("Focus Stays In Modal Scope", func(t *testing.T) {
+		runloop, _ := startControls(t)
+		modal := &touch.BasicLayer{}
+		first, second := newActionControl(image.Rect(0, 10, 10, 20)), newActionControl(image.Rect(10, 10, 20, 20))
+		runloop.Do(func() error {
+			modal.Self = modal
+			modal.SetFrame(runloop.Window.Rectangle)
+			modal.AddChild(first, second)
+			runloop.Window.AddChild(modal)
+			return nil
+		})
+		for idx, want := range []touch.Focusable{first, second, first} {
+			pressKey(runloop, touch.KeyNext)
+			if f := focused(runloop); f != want {
+				t.Fatalf("Step %d: expected focus within the modal layer", idx)
+			}
+		}
+		pressKey(runloop, touch.KeyPrevious)
+		if f := focused(runloop); f != second {
+			t.Error("Expected focus to wrap backward within the modal layer")
+		}
+	})
+
+	activation := []struct {
+		name    string
+		key     touch.Key
+		actions int
+	}{
+		{"Enter Activates Control", touch.KeyEnter, 1},
+		{"Keypad Enter Activates Control", touch.KeyKPEnter, 1},
+		{"Space Activates Control", touch.KeySpace, 1},
+		{"Select Activates Control", touch.KeySelect, 1},
+		{"OK Activates Control", touch.KeyOK, 1},
+		{"Other Keys Don't Activate Control", touch.KeyEscape, 0},
+	}
+	for _, test := range activation {
+		t.Run(test.name, func(t *testing.T) {
+			runloop, controls := startControls(t)
+			pressKey(runloop, touch.KeyNext)
+			pressKey(runloop, touch.KeyNext)
+
+			runloop.InjectKey(touch.KeyEvent{Key: test.key, Pressed: true})
+			runloop.Do(func() error {
+				if len(controls[1].actions) != 0 {
+					t.Error("Control was activated before the key was released")
+				}
+				return nil
+			})
+			runloop.InjectKey(touch.KeyEvent{Key: test.key})
+			runloop.Do(func() error {
+				if len(controls[1].actions) != test.actions {
+					t.Errorf("Expected %d actions on the focused control, got %v", test.actions, controls[1].actions)
+				}
+				if test.actions > 0 && controls[1].actions[0] != touch.ControlTapped {
+					t.Errorf("Expected ControlTapped, got %v", controls[1].actions[0])
+				}
+				if len(controls[0].actions) != 0 || len(controls[2].actions) != 0 {
+					t.Error("Controls without the focus were activated")
+				}
+				return nil
+			})
+		})
+	}
+
+	t.Run("Disabled Control Loses Focus", func(t *testing.T) {
+		runloop, controls := startControls(t)
+		pressKey(runloop, touch.KeyNext)
+		runloop.Do(func() error {
+			controls[0].SetDisabled(true)
+			return nil
+		})
+		pressKey(runloop, touch.KeyEnter)
+		if f := focused(runloop); f != nil {
+			t.Errorf("Expected the disabled control to lose the focus, got %T", f)
+		}
+		pressKey(runloop, touch.KeyNext)
+		if f := focused(runloop); f != controls[1] {
+			t.Error("Expected the disabled control to be skipped")
+		}
+		runloop.Do(func() error {
+			if len(controls[0].actions) != 0 {
+				t.Errorf("Disabled control was activated: %v", controls[0].actions)
+			}
+			return nil
+		})
+	})
+
+	t.Run("Removed Control Loses Focus", func(t *testing.T) {
+		runloop, controls := startControls(t)
+		pressKey(runloop, touch.KeyNext)
+		pressKey(runloop, touch.KeyNext)
+		runloop.Do(func() error {
+			controls[1].RemoveFromParent()
+			return nil
+		})
+		pressKey(runloop, touch.KeyEnter)
+		if f := focused(runloop); f != nil {
+			t.Errorf("Expected the removed control to lose the focus, got %T", f)
+		}
+		pressKey(runloop, touch.KeyPrevious)
+		if f := focused(runloop); f != controls[2] {
+			t.Error("Expected the removed control to be skipped")
+		}
+		runloop.Do(func() error {
+			if len(controls[1].actions) != 0 {
+				t.Errorf("Removed control was activated: %v", controls[1].actions)
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,66 @@
+package touch
+
+import "time"
+
+// DefaultFrameRate is the rate of frame callbacks when MaxFPS is not set.
+const DefaultFrameRate = 60
+
+// FrameCallback is a function called once per frame, registered with AddFrameCallback.
+type FrameCallback struct {
+	runloop *RunLoop
+	fn      func(frameTime time.Time)
+	removed bool
+}
+
+// AddFrameCallback registers fn to be called on the runloop before each frame
+// is drawn, with the frame's timestamp. While any callbacks are registered, the
+// runloop draws frames continuously at MaxFPS, or DefaultFrameRate if unset.
+// Must be called from the runloop.
+func (runloop *RunLoop) AddFrameCallback(fn func(frameTime time.Time)) *FrameCallback {
+	cb := &FrameCallback{runloop: runloop, fn: fn}
+	runloop.frameCallbacks = append(runloop.frameCallbacks, cb)
+	if runloop.frameTimer == nil {
+		runloop.frameTimer = runloop.After(runloop.frameWait(), runloop.drawFrame)
+	}
+	return cb
+}
+
+// Remove unregisters the callback. Once no callbacks remain, the runloop goes idle.
+// Must be called from the runloop.
+func (cb *FrameCallback) Remove() {
+	if cb.removed {
+		return
+	}
+	cb.removed = true
+	callbacks := cb.runloop.frameCallbacks
+	for idx := range callbacks {
+		if callbacks[idx] == cb {
+			cb.runloop.frameCallbacks = append(callbacks[:idx], callbacks[idx+1:]...)
+			return
+		}
+	}
+}
+
+// frameWait returns the time until the next frame at the callback frame rate.
+func (runloop *RunLoop) frameWait() time.Duration {
+	interval := runloop.frameBudget()
+	if interval <= 0 {
+		interval = time.Second / DefaultFrameRate
+	}
+	wait := runloop.lastFrame.Add(interval).Sub(runloop.clock().Now())
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// runFrameCallbacks calls each registered callback with the frame time.
+func (runloop *RunLoop) runFrameCallbacks(frameTime time.Time) {
+	// Callbacks may add or remove callbacks
+	callbacks := append([]*FrameCallback(nil), runloop.frameCallbacks...)
+	for _, cb := range callbacks {
+		if !cb.removed {
+			cb.fn(frameTime)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package touch_test
+
+import (
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+func TestFrameCallbacks(t *testing.T) {
+	const frame = 100 * time.Millisecond
+	start := time.Unix(0, 0)
+	startRunLoop := func(t *testing.T) (*touch.RunLoop, *touch.ManualClock) {
+		clock := touch.NewManualClock(start)
+		runloop := startHeadless(t, clock)
+		runloop.Do(func() error {
+			runloop.MaxFPS = 10
+			return nil
+		})
+		return runloop, clock
+	}
+	// advance moves the clock forward one frame at a time, waiting for each frame.
+	advance := func(runloop *touch.RunLoop, clock *touch.ManualClock, frames int) {
+		for ; frames > 0; frames-- {
+			clock.Advance(frame)
+			waitForTasks(runloop)
+		}
+	}
+
+	t.Run("Callbacks Run Once Per Frame", func(t *testing.T) {
+		runloop, clock := startRunLoop(t)
+		var first, second []time.Time
+		runloop.Do(func() error {
+			runloop.AddFrameCallback(func(frameTime time.Time) { first = append(first, frameTime) })
+			runloop.AddFrameCallback(func(frameTime time.Time) { second = append(second, frameTime) })
+			return nil
+		})
+		advance(runloop, clock, 3)
+		runloop.Do(func() error {
+			if len(first) != 3 || len(second) != 3 {
+				t.Fatalf("Expected 3 calls of each callback, got %d and %d", len(first), len(second))
+			}
+			for idx := range first {
+				if want := start.Add(time.Duration(idx+1) * frame); !first[idx].Equal(want) || !second[idx].Equal(want) {
+					t.Errorf("Frame %d: expected both callbacks at %v, got %v and %v", idx, want, first[idx], second[idx])
+				}
+			}
+			return nil
+		})
+	})
+	t.Run("Remove From Callback", func(t *testing.T) {
+		runloop, clock := startRunLoop(t)
+		calls := 0
+		runloop.Do(func() error {
+			var cb *touch.FrameCallback
+			cb = runloop.AddFrameCallback(func(time.Time) {
+				if calls++; calls == 2 {
+					cb.Remove()
+				}
+			})
+			return nil
+		})
+		advance(runloop, clock, 5)
+		runloop.Do(func() error {
+			if calls != 2 {
+				t.Errorf("Expected the callback to stop after removing itself, got %d calls", calls)
+			}
+			return nil
+		})
+		if pending := clock.PendingTimers(); pending != 0 {
+			t.Errorf("Expected the runloop to go idle, got %d pending timers", pending)
+		}
+	})
+	t.Run("Runloop Goes Idle", func(t *testing.T) {
+		runloop, clock := startRunLoop(t)
+		var kept, removed *touch.FrameCallback
+		calls := 0
+		runloop.Do(func() error {
+			kept = runloop.AddFrameCallback(func(time.Time) { calls++ })
+			removed = runloop.AddFrameCallback(func(time.Time) {})
+			return nil
+		})
+		advance(runloop, clock, 1)
+		runloop.Do(func() error {
+			removed.Remove()
+			return nil
+		})
+		advance(runloop, clock, 1)
+		if pending := clock.PendingTimers(); pending != 1 {
+			t.Errorf("Expected frames to continue while a callback remains, got %d pending timers", pending)
+		}
+
+		runloop.Do(func() error {
+			kept.Remove()
+			// Removing twice is harmless
+			kept.Remove()
+			return nil
+		})
+		// The frame already scheduled is drawn, and no more are requested
+		advance(runloop, clock, 1)
+		if pending := clock.PendingTimers(); pending != 0 {
+			t.Errorf("Expected the runloop to go idle, got %d pending timers", pending)
+		}
+		advance(runloop, clock, 3)
+		runloop.Do(func() error {
+			if calls != 2 {
+				t.Errorf("Expected 2 calls before the callback was removed, got %d", calls)
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,104 @@
+package touch
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FrameStats describes the work done to update the display for one frame.
+type FrameStats struct {
+	Start     time.Time
+	DrawTime  time.Duration
+	FlushTime time.Duration
+	// Number and total area of the rects flushed to the display
+	DirtyRects int
+	DirtyArea  int
+	// PixelsConverted counts the pixels converted to the display's format, which
+	// may include more than the dirty area. Headless displays convert none.
+	PixelsConverted int
+}
+
+// LogFrameStats returns a Window.OnFrame handler that logs frames taking longer than threshold.
+func LogFrameStats(logger *log.Logger, threshold time.Duration) func(FrameStats) {
+	return func(s FrameStats) {
+		if s.DrawTime+s.FlushTime > threshold {
+			logger.Printf(
+				"Updated: Draw %v / Flush %v, %d rects, %d pixels",
+				s.DrawTime, s.FlushTime, s.DirtyRects, s.DirtyArea,
+			)
+		}
+	}
+}
+
+// FrameStatsSummary holds aggregates over recent frames.
+type FrameStatsSummary struct {
+	Frames             int
+	FPS                float64
+	DrawP50, DrawP95   time.Duration
+	FlushP50, FlushP95 time.Duration
+	MeanDirtyArea      int
+}
+
+// FrameStatsAggregator keeps rolling statistics over the most recent frames.
+// Its methods are safe to call from any goroutine.
+type FrameStatsAggregator struct {
+	mu     sync.Mutex
+	frames []FrameStats
+	next   int
+}
+
+// NewFrameStatsAggregator returns an aggregator over the last size frames.
+func NewFrameStatsAggregator(size int) *FrameStatsAggregator {
+	return &FrameStatsAggregator{frames: make([]FrameStats, 0, size)}
+}
+
+// Record adds a frame to the aggregate. It may be used as a Window.OnFrame handler.
+func (a *FrameStatsAggregator) Record(s FrameStats) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.frames) < cap(a.frames) {
+		a.frames = append(a.frames, s)
+	} else {
+		a.frames[a.next] = s
+		a.next = (a.next + 1) % len(a.frames)
+	}
+}
+
+func (a *FrameStatsAggregator) Summary() (sum FrameStatsSummary) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	count := len(a.frames)
+	if count == 0 {
+		return
+	}
+	sum.Frames = count
+
+	draw := make([]time.Duration, count)
+	flush := make([]time.Duration, count)
+	first, last := a.frames[0].Start, a.frames[0].Start
+	for idx, s := range a.frames {
+		draw[idx], flush[idx] = s.DrawTime, s.FlushTime
+		sum.MeanDirtyArea += s.DirtyArea
+		if s.Start.Before(first) {
+			first = s.Start
+		}
+		if s.Start.After(last) {
+			last = s.Start
+		}
+	}
+	sum.MeanDirtyArea /= count
+	if elapsed := last.Sub(first); elapsed > 0 {
+		sum.FPS = float64(count-1) / elapsed.Seconds()
+	}
+	sum.DrawP50, sum.DrawP95 = percentile(draw, 50), percentile(draw, 95)
+	sum.FlushP50, sum.FlushP95 = percentile(flush, 50), percentile(flush, 95)
+	return
+}
+
+// percentile sorts values and returns the p'th percentile.
+func percentile(values []time.Duration, p int) time.Duration {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return values[(len(values)-1)*p/100]
+}
@@ -0,0 +1,111 @@
+package touch_test
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+func TestPercentile(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name     string
+		values   []time.Duration
+		p        int
+		expected time.Duration
+	}{
+		{"Single", []time.Duration{5 * ms}, 95, 5 * ms},
+		{"Median Of Odd", []time.Duration{3 * ms, 1 * ms, 2 * ms}, 50, 2 * ms},
+		// The lower of the middle values
+		{"Median Of Even", []time.Duration{4 * ms, 1 * ms, 3 * ms, 2 * ms}, 50, 2 * ms},
+		{"Minimum", []time.Duration{3 * ms, 1 * ms, 2 * ms}, 0, 1 * ms},
+		{"Maximum", []time.Duration{3 * ms, 1 * ms, 2 * ms}, 100, 3 * ms},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if v := touch.Percentile(test.values, test.p); v != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, v)
+			}
+		})
+	}
+	t.Run("P95 Of 100", func(t *testing.T) {
+		values := make([]time.Duration, 100)
+		for idx := range values {
+			// Reversed, to check that values are sorted
+			values[idx] = time.Duration(100-idx) * ms
+		}
+		if v := touch.Percentile(values, 95); v != 95*ms {
+			t.Errorf("Expected 95ms, got %v", v)
+		}
+	})
+}
+
+func TestFrameStatsAggregator(t *testing.T) {
+	start := time.Unix(0, 0)
+	frame := func(idx int) touch.FrameStats {
+		return touch.FrameStats{
+			Start:     start.Add(time.Duration(idx) * 100 * time.Millisecond),
+			DrawTime:  time.Duration(idx) * time.Millisecond,
+			FlushTime: time.Duration(2*idx) * time.Millisecond,
+			DirtyArea: 10 * idx,
+		}
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		if sum := touch.NewFrameStatsAggregator(4).Summary(); sum != (touch.FrameStatsSummary{}) {
+			t.Errorf("Expected an empty summary, got %+v", sum)
+		}
+	})
+	t.Run("Partially Filled", func(t *testing.T) {
+		agg := touch.NewFrameStatsAggregator(4)
+		for idx := 1; idx <= 3; idx++ {
+			agg.Record(frame(idx))
+		}
+		expected := touch.FrameStatsSummary{
+			Frames:        3,
+			FPS:           10,
+			DrawP50:       2 * time.Millisecond,
+			DrawP95:       2 * time.Millisecond,
+			FlushP50:      4 * time.Millisecond,
+			FlushP95:      4 * time.Millisecond,
+			MeanDirtyArea: 20,
+		}
+		if sum := agg.Summary(); sum != expected {
+			t.Errorf("Expected %+v, got %+v", expected, sum)
+		}
+	})
+	t.Run("Oldest Frames Are Replaced", func(t *testing.T) {
+		agg := touch.NewFrameStatsAggregator(4)
+		for idx := 1; idx <= 10; idx++ {
+			agg.Record(frame(idx))
+		}
+		// Frames 7 through 10 remain
+		expected := touch.FrameStatsSummary{
+			Frames:        4,
+			FPS:           10,
+			DrawP50:       8 * time.Millisecond,
+			DrawP95:       9 * time.Millisecond,
+			FlushP50:      16 * time.Millisecond,
+			FlushP95:      18 * time.Millisecond,
+			MeanDirtyArea: 85,
+		}
+		if sum := agg.Summary(); sum != expected {
+			t.Errorf("Expected %+v, got %+v", expected, sum)
+		}
+	})
+	t.Run("Pixels Converted", func(t *testing.T) {
+		runloop := startHeadless(t, nil)
+		var stats []touch.FrameStats
+		runloop.Do(func() error {
+			runloop.Window.OnFrame = func(s touch.FrameStats) { stats = append(stats, s) }
+			runloop.Window.Redraw(func(*image.RGBA) {})
+			return nil
+		})
+		// Redraw passes each dirty rect to flush for conversion
+		if len(stats) != 1 || stats[0].PixelsConverted != 32*32 || stats[0].DirtyArea != 32*32 {
+			t.Errorf("Expected the whole window to be converted once, got %+v", stats)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package touch
+
+import (
+	"image"
+	"time"
+)
+
+// InjectTouch delivers a touch event in screen coordinates, as if it came from
+// an input device. It is safe to call from any goroutine.
+func (runloop *RunLoop) InjectTouch(event TouchEvent) {
+	runloop.Post(func() {
+		runloop.dispatchTouch(event)
+	})
+}
+
+// InjectKey delivers a key event, as if it came from an input device.
+// It is safe to call from any goroutine.
+func (runloop *RunLoop) InjectKey(event KeyEvent) {
+	runloop.Post(func() {
+		runloop.Window.HandleKey(event)
+	})
+}
+
+// InjectSteps delivers steps of a scroll wheel or rotary encoder as presses of
+// KeyNext, or of KeyPrevious if steps is negative. It is safe to call from any goroutine.
+func (runloop *RunLoop) InjectSteps(steps int) {
+	sendSteps(steps, runloop.InjectKey)
+}
+
+// The gesture helpers below block for the duration of the gesture.
+// They must not be called from the runloop goroutine.
+
+// Tap presses and releases at pt.
+func (runloop *RunLoop) Tap(pt image.Point) {
+	runloop.InjectTouch(TouchEvent{Point: pt, Pressed: true, Pressure: 0xFF})
+	// Hold long enough for the highlight to be drawn
+	time.Sleep(50 * time.Millisecond)
+	runloop.InjectTouch(TouchEvent{Point: pt, Pressure: 0xFF})
+}
+
+// LongPress presses at pt and releases once a long press has been recognized.
+func (runloop *RunLoop) LongPress(pt image.Point) {
+	runloop.InjectTouch(TouchEvent{Point: pt, Pressed: true, Pressure: 0xFF})
+	time.Sleep(LongPressDuration + 100*time.Millisecond)
+	runloop.InjectTouch(TouchEvent{Point: pt, Pressure: 0xFF})
+}
+
+// Drag presses at from, moves to the point to over duration, and releases.
+func (runloop *RunLoop) Drag(from, to image.Point, duration time.Duration) {
+	const interval = time.Second / 60
+	steps := int(duration / interval)
+	if steps < 1 {
+		steps = 1
+	}
+
+	runloop.InjectTouch(TouchEvent{Point: from, Pressed: true, Pressure: 0xFF})
+	delta := to.Sub(from)
+	for step := 1; step <= steps; step++ {
+		time.Sleep(duration / time.Duration(steps))
+		pt := from.Add(delta.Mul(step).Div(steps))
+		runloop.InjectTouch(TouchEvent{Point: pt, Pressed: true, Pressure: 0xFF})
+	}
+	runloop.InjectTouch(TouchEvent{Point: to, Pressure: 0xFF})
+}
@@ -0,0 +1,108 @@
+package touch_test
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+// recordingControl is a control that records its touches, as well as its actions.
+type recordingControl struct {
+	actionControl
+	touches []touch.TouchEvent
+}
+
+func (c *recordingControl) StartTouch(e touch.TouchEvent) {
+	c.touches = append(c.touches, e)
+	c.ControlLayer.StartTouch(e)
+}
+
+func (c *recordingControl) UpdateTouch(e touch.TouchEvent) {
+	c.touches = append(c.touches, e)
+	c.ControlLayer.UpdateTouch(e)
+}
+
+func (c *recordingControl) EndTouch(e touch.TouchEvent) {
+	c.touches = append(c.touches, e)
+	c.ControlLayer.EndTouch(e)
+}
+
+func TestInjection(t *testing.T) {
+	startControl := func(t *testing.T) (*touch.RunLoop, *recordingControl) {
+		runloop := startHeadless(t, nil)
+		control := &recordingControl{}
+		runloop.Do(func() error {
+			control.Self = control
+			control.SetFrame(image.Rect(10, 10, 30, 30))
+			runloop.Window.AddChild(control)
+			return nil
+		})
+		return runloop, control
+	}
+	// expectActions checks the actions handled by control, once injected events have been delivered.
+	expectActions := func(t *testing.T, runloop *touch.RunLoop, control *recordingControl, want ...touch.ControlAction) {
+		t.Helper()
+		runloop.Do(func() error {
+			ok := len(control.actions) == len(want)
+			for idx := 0; ok && idx < len(want); idx++ {
+				ok = control.actions[idx] == want[idx]
+			}
+			if !ok {
+				t.Errorf("Expected actions %v, got %v", want, control.actions)
+			}
+			return nil
+		})
+	}
+
+	t.Run("Tap Fires Action", func(t *testing.T) {
+		runloop, control := startControl(t)
+		runloop.Tap(image.Pt(15, 15))
+		expectActions(t, runloop, control, touch.ControlTapped)
+	})
+	t.Run("Tap Outside Control", func(t *testing.T) {
+		runloop, control := startControl(t)
+		runloop.Tap(image.Pt(5, 5))
+		expectActions(t, runloop, control)
+	})
+	t.Run("Long Press Fires Long Press Action", func(t *testing.T) {
+		runloop, control := startControl(t)
+		start := time.Now()
+		runloop.LongPress(image.Pt(15, 15))
+		if held := time.Since(start); held < touch.LongPressDuration {
+			t.Errorf("Expected the press to be held for at least %v, released after %v", touch.LongPressDuration, held)
+		}
+		// The release after a long press does not also tap
+		expectActions(t, runloop, control, touch.ControlLongPress)
+	})
+	t.Run("Drag Moves And Releases Inside Target", func(t *testing.T) {
+		runloop, control := startControl(t)
+		from, to := image.Pt(12, 20), image.Pt(24, 20)
+		runloop.Drag(from, to, 50*time.Millisecond)
+		runloop.Do(func() error {
+			touches := control.touches
+			if len(touches) < 3 {
+				t.Fatalf("Expected a press, moves and a release, got %d events", len(touches))
+			}
+			if first := touches[0]; first.Point != from || !first.Pressed {
+				t.Errorf("Expected a press at %v, got %+v", from, first)
+			}
+			moves := touches[1 : len(touches)-1]
+			for idx, move := range moves {
+				if !move.Pressed || move.Y != from.Y || (idx > 0 && move.X <= moves[idx-1].X) {
+					t.Errorf("Expected pressed moves toward %v, got %+v", to, move)
+				}
+			}
+			if moves[len(moves)-1].Point != to {
+				t.Errorf("Expected the last move to reach %v, got %v", to, moves[len(moves)-1].Point)
+			}
+			if last := touches[len(touches)-1]; last.Point != to || last.Pressed || !last.In(control.Rectangle) {
+				t.Errorf("Expected a release at %v inside the control, got %+v", to, last)
+			}
+			return nil
+		})
+		// The drag stayed within the control, so it taps
+		expectActions(t, runloop, control, touch.ControlTapped)
+	})
+}
@@ -0,0 +1,188 @@
+package touch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+)
+
+// LayerInfo describes a layer and its subtree, for debugging layout and hit testing.
+type LayerInfo struct {
+	Type       string                 `json:"type"`
+	Frame      image.Rectangle        `json:"frame"`
+	Opaque     image.Rectangle        `json:"opaque"`
+	Background string                 `json:"background,omitempty"`
+	Radius     int                    `json:"radius,omitempty"`
+	Opacity    float64                `json:"opacity"`
+	Hidden     bool                   `json:"hidden,omitempty"`
+	State      map[string]interface{} `json:"state,omitempty"`
+	Children   []LayerInfo            `json:"children,omitempty"`
+}
+
+// Inspector is implemented by layers to describe their state in a LayerInfo.
+// Implementations should call the Inspect method of the layer they embed.
+type Inspector interface {
+	Inspect(info *LayerInfo)
+}
+
+// InspectLayer describes layer and its subtree. Must be called from the runloop.
+func InspectLayer(layer Layer) LayerInfo {
+	info := LayerInfo{
+		Type:    fmt.Sprintf("%T", layer),
+		Frame:   layer.Frame(),
+		Opaque:  layer.OpaqueRect(),
+		Opacity: 1,
+	}
+	if inspector, ok := layer.(Inspector); ok {
+		inspector.Inspect(&info)
+	}
+	for _, child := range layer.Children() {
+		info.Children = append(info.Children, InspectLayer(child))
+	}
+	return info
+}
+
+// WriteText writes the layer tree as indented text, one layer per line.
+func (info LayerInfo) WriteText(w io.Writer) {
+	info.writeText(w, 0)
+}
+
+func (info LayerInfo) writeText(w io.Writer, depth int) {
+	fmt.Fprintf(w, "%s%s %v", strings.Repeat("  ", depth), info.Type, info.Frame)
+	if !info.Opaque.Empty() {
+		fmt.Fprintf(w, " opaque=%v", info.Opaque)
+	}
+	if info.Background != "" {
+		fmt.Fprintf(w, " background=%s", info.Background)
+	}
+	if info.Radius != 0 {
+		fmt.Fprintf(w, " radius=%d", info.Radius)
+	}
+	if info.Opacity != 1 {
+		fmt.Fprintf(w, " opacity=%.2f", info.Opacity)
+	}
+	if info.Hidden {
+		fmt.Fprint(w, " hidden")
+	}
+	keys := make([]string, 0, len(info.State))
+	for key := range info.State {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, " %s=%q", key, fmt.Sprint(info.State[key]))
+	}
+	fmt.Fprintln(w)
+
+	for _, child := range info.Children {
+		child.writeText(w, depth+1)
+	}
+}
+
+// WriteJSON writes the layer tree as indented JSON, in the format served by DebugServer.
+func (info LayerInfo) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(info)
+}
+
+// DumpLayersOnSignal passes the window's layer tree to dump each time one of the
+// given signals is received, until ctx is done. ctx should be the context passed
+// to Run, as the layer tree can't be inspected once the runloop stops.
+// dump is called from a goroutine of its own, and may be slow.
+func (runloop *RunLoop) DumpLayersOnSignal(ctx context.Context, dump func(LayerInfo), sig ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig...)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ch:
+			case <-ctx.Done():
+				return
+			}
+			var info LayerInfo
+			f := runloop.DoAsync(func() error {
+				info = InspectLayer(runloop.Window)
+				return nil
+			})
+			select {
+			case <-f.Done():
+				dump(info)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// setState records widget-specific state in info.
+func (info *LayerInfo) setState(key string, value interface{}) {
+	if info.State == nil {
+		info.State = map[string]interface{}{}
+	}
+	info.State[key] = value
+}
+
+func colorString(c color.Color) string {
+	if c == nil {
+		return ""
+	}
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	return fmt.Sprintf("#%02X%02X%02X%02X", rgba.R, rgba.G, rgba.B, rgba.A)
+}
+
+func (layer *BasicLayer) Inspect(info *LayerInfo) {
+	info.Background = colorString(layer.Background)
+	info.Radius = layer.Radius
+	info.Opacity = layer.Opacity()
+	info.Hidden = layer.hidden
+}
+
+func (tl *TextLayer) Inspect(info *LayerInfo) {
+	tl.BasicLayer.Inspect(info)
+	info.setState("text", tl.Text)
+	info.setState("color", colorString(tl.Color))
+}
+
+func (i *ImageLayer) Inspect(info *LayerInfo) {
+	i.BasicLayer.Inspect(info)
+	if i.Image != nil {
+		info.setState("image", i.Image.Bounds().Size())
+	}
+	if i.Tint != nil {
+		info.setState("tint", colorString(i.Tint))
+	}
+}
+
+func (c *ControlLayer) Inspect(info *LayerInfo) {
+	c.BasicLayer.Inspect(info)
+	info.setState("state", c.State.String())
+}
+
+func (s ControlStateMask) String() string {
+	if s == ControlStateNormal {
+		return "normal"
+	}
+	var names []string
+	for _, flag := range []struct {
+		mask ControlStateMask
+		name string
+	}{
+		{ControlStateHighlighted, "highlighted"},
+		{ControlStateDisabled, "disabled"},
+		{ControlStateFocused, "focused"},
+	} {
+		if s&flag.mask != 0 {
+			names = append(names, flag.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
@@ -0,0 +1,66 @@
+package touch_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+func TestInspect(t *testing.T) {
+	runloop := startHeadless(t, nil)
+	layer := &touch.ControlLayer{}
+	runloop.Do(func() error {
+		layer.Self = layer
+		layer.SetFrame(image.Rect(2, 2, 20, 12))
+		layer.Background = color.White
+		runloop.Window.AddChild(layer)
+		return nil
+	})
+
+	t.Run("JSON Round Trip", func(t *testing.T) {
+		var info touch.LayerInfo
+		runloop.Do(func() error {
+			info = touch.InspectLayer(runloop.Window)
+			return nil
+		})
+		var buf bytes.Buffer
+		if err := info.WriteJSON(&buf); err != nil {
+			t.Fatal(err)
+		}
+		var decoded touch.LayerInfo
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded.Children) != 1 {
+			t.Fatalf("Expected one child, got %+v", decoded)
+		}
+		child := decoded.Children[0]
+		if child.Type != "*touch.ControlLayer" || child.Frame != image.Rect(2, 2, 20, 12) ||
+			child.Background != "#FFFFFFFF" || child.State["state"] != "normal" {
+			t.Errorf("Unexpected child %+v", child)
+		}
+	})
+	t.Run("Dump On Signal", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		dumped := make(chan touch.LayerInfo, 1)
+		runloop.DumpLayersOnSignal(ctx, func(info touch.LayerInfo) { dumped <- info }, syscall.SIGUSR1)
+		syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+		select {
+		case info := <-dumped:
+			if len(info.Children) != 1 {
+				t.Errorf("Expected the window's layer tree, got %+v", info)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for a dump")
+		}
+	})
+}
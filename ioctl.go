@@ -0,0 +1,15 @@
+package touch
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// ioctl calls a device's ioctl with a pointer to its argument, such as the
+// termios of a terminal, or the settings of an spidev device.
+func ioctl(fd uintptr, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
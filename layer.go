@@ -3,6 +3,7 @@ package touch
 import (
 	"image"
 	"image/color"
+	"image/draw"
 )
 
 type Layer interface {
@@ -63,6 +64,9 @@ type BasicLayer struct {
 
 	parent   Layer
 	children []Layer
+	// Stored inverted, so that layers are opaque by default
+	transparency uint8
+	hidden       bool
 }
 
 // Layer returns a layer interface to the outermost struct associated with this layer.
@@ -73,6 +77,20 @@ func (layer *BasicLayer) Layer() Layer {
 	return layer
 }
 
+// Window returns the window containing this layer, or nil if it is not in a window.
+func (layer *BasicLayer) Window() *Window {
+	return windowOf(layer.Layer())
+}
+
+func windowOf(layer Layer) *Window {
+	for ; layer != nil; layer = layer.Parent() {
+		if w, ok := layer.(*Window); ok {
+			return w
+		}
+	}
+	return nil
+}
+
 func (layer *BasicLayer) Frame() image.Rectangle {
 	return layer.Rectangle
 }
@@ -120,6 +138,9 @@ func (layer *BasicLayer) RemoveChild(child Layer) {
 }
 
 func (layer *BasicLayer) HitTest(event TouchEvent) LayerTouchDelegate {
+	if !layer.Visible() {
+		return nil
+	}
 	for idx := len(layer.children); idx > 0; idx-- {
 		if target := layer.children[idx-1].HitTest(event); target != nil {
 			return target
@@ -138,7 +159,49 @@ func (layer *BasicLayer) HitTest(event TouchEvent) LayerTouchDelegate {
 	return nil
 }
 
+// Hidden returns true if the layer and its subtree are hidden.
+func (layer *BasicLayer) Hidden() bool {
+	return layer.hidden
+}
+
+// SetHidden hides or shows the layer and its subtree, without removing it from its parent.
+// Hidden layers are not drawn, and receive neither touches nor focus.
+func (layer *BasicLayer) SetHidden(hidden bool) {
+	if hidden != layer.hidden {
+		layer.hidden = hidden
+		layer.Invalidate()
+	}
+}
+
+// Visible returns true if the layer is neither hidden nor fully transparent.
+func (layer *BasicLayer) Visible() bool {
+	return !layer.hidden && layer.transparency < 0xFF
+}
+
+// Opacity returns the opacity of the layer and its subtree, from 0 to 1.
+func (layer *BasicLayer) Opacity() float64 {
+	return float64(0xFF-layer.transparency) / 0xFF
+}
+
+// SetOpacity sets the opacity of the layer and its subtree, from 0 to 1.
+// Translucent layers are composited through a temporary buffer, and
+// fully transparent layers are treated as hidden.
+func (layer *BasicLayer) SetOpacity(opacity float64) {
+	if opacity < 0 {
+		opacity = 0
+	} else if opacity > 1 {
+		opacity = 1
+	}
+	if t := 0xFF - uint8(opacity*0xFF+0.5); t != layer.transparency {
+		layer.transparency = t
+		layer.Invalidate()
+	}
+}
+
 func (layer *BasicLayer) OpaqueRect() image.Rectangle {
+	if layer.hidden || layer.transparency > 0 {
+		return image.Rectangle{}
+	}
 	if layer.Background != nil {
 		if _, _, _, a := layer.Background.RGBA(); a == 0xFFFF {
 			return layer.Rectangle.Inset((layer.Radius + 1) / 2)
@@ -178,6 +241,28 @@ func (layer *BasicLayer) InvalidateRect(rect image.Rectangle) {
 
 // DrawChildren draws child layers IFF they are visible in ctx, and (need display or overlap rect)
 func (layer *BasicLayer) Render(ctx DrawingContext) {
+	if !layer.Visible() {
+		return
+	}
+	if layer.transparency > 0 {
+		layer.renderTranslucent(ctx)
+		return
+	}
+	layer.renderContents(ctx)
+}
+
+// renderTranslucent renders into a temporary buffer, and composites it with the layer's opacity.
+func (layer *BasicLayer) renderTranslucent(ctx DrawingContext) {
+	bounds := ctx.Bounds()
+	buffer := &Buffer{RGBA: image.NewRGBA(bounds)}
+	layer.renderContents(buffer)
+
+	mask := image.NewUniform(color.Alpha{0xFF - layer.transparency})
+	draw.DrawMask(ctx.Image(), bounds, buffer.RGBA, bounds.Min, mask, image.Point{}, draw.Over)
+	ctx.SetDirty(bounds)
+}
+
+func (layer *BasicLayer) renderContents(ctx DrawingContext) {
 	// Draw the smallest rect of this layer that is not occluded by opaque children
 	rect := ctx.Bounds()
 	for _, child := range layer.children {
@@ -0,0 +1,140 @@
+package touch_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+// pixelAt returns the color of the window's buffer at x, y, after the pending frame is drawn.
+func pixelAt(runloop *touch.RunLoop, x, y int) (c color.RGBA) {
+	waitForTasks(runloop)
+	runloop.Do(func() error {
+		c = runloop.Window.Buffer.RGBAAt(x, y)
+		return nil
+	})
+	return
+}
+
+func TestOpacity(t *testing.T) {
+	t.Run("Buffered Layer Is Composited Once", func(t *testing.T) {
+		runloop := startHeadless(t, nil)
+		layer := &touch.BufferedLayer{}
+		runloop.Do(func() error {
+			runloop.Window.Background = color.Black
+			layer.Self = layer
+			layer.Background = color.White
+			layer.SetFrame(image.Rect(0, 0, 10, 10))
+			layer.SetOpacity(0.5)
+			runloop.Window.AddChild(layer)
+			return nil
+		})
+		for i := 0; i < 3; i++ {
+			if c := pixelAt(runloop, 5, 5); c.R < 126 || c.R > 129 {
+				t.Errorf("Redraw %d: expected 50%% white over black, got %v", i, c)
+			}
+			runloop.Do(func() error {
+				layer.Invalidate()
+				return nil
+			})
+		}
+	})
+	t.Run("Window Opacity Is Stable", func(t *testing.T) {
+		runloop := startHeadless(t, nil)
+		runloop.Do(func() error {
+			runloop.Window.Background = color.White
+			runloop.Window.SetOpacity(0.5)
+			return nil
+		})
+		for i := 0; i < 3; i++ {
+			// Premultiplied, so displays show 50% gray
+			if c := pixelAt(runloop, 5, 5); c.R < 126 || c.R > 129 || c.A != c.R {
+				t.Errorf("Redraw %d: expected 50%% transparent white, got %v", i, c)
+			}
+			runloop.Do(func() error {
+				runloop.Window.Invalidate()
+				return nil
+			})
+		}
+	})
+}
+
+// focusableLayer is a control that can receive the keyboard focus.
+type focusableLayer struct {
+	touch.ControlLayer
+}
+
+func TestHidden(t *testing.T) {
+	red := color.RGBA{0xFF, 0, 0, 0xFF}
+	blue := color.RGBA{0, 0, 0xFF, 0xFF}
+	runloop := startHeadless(t, nil)
+	layer := &focusableLayer{}
+	runloop.Do(func() error {
+		layer.Self = layer
+		layer.Background = red
+		layer.SetFrame(image.Rect(0, 0, 10, 10))
+		runloop.Window.Background = blue
+		runloop.Window.AddChild(layer)
+		return nil
+	})
+	hitTest := func() (target touch.LayerTouchDelegate) {
+		runloop.Do(func() error {
+			// The window's background blocks touches, so test from the layer's parent down
+			target = layer.HitTest(touch.TouchEvent{Point: image.Pt(5, 5), Pressed: true})
+			return nil
+		})
+		return
+	}
+
+	if c := pixelAt(runloop, 5, 5); c != red {
+		t.Fatalf("Expected visible layer to be drawn, got %v", c)
+	}
+	if hitTest() == nil {
+		t.Fatal("Expected visible layer to receive touches")
+	}
+
+	runloop.Do(func() error {
+		layer.SetHidden(true)
+		return nil
+	})
+	t.Run("Hidden Layer Is Not Rendered", func(t *testing.T) {
+		if c := pixelAt(runloop, 5, 5); c != blue {
+			t.Errorf("Expected the window beneath a hidden layer, got %v", c)
+		}
+		if opaque := layer.OpaqueRect(); !opaque.Empty() {
+			t.Errorf("Hidden layer should not be opaque: %v", opaque)
+		}
+	})
+	t.Run("Hidden Layer Is Not Hit Tested", func(t *testing.T) {
+		if target := hitTest(); target != nil {
+			t.Errorf("Expected no touch target, got %T", target)
+		}
+	})
+	t.Run("Hidden Layer Is Not Focusable", func(t *testing.T) {
+		runloop.Do(func() error {
+			runloop.Window.FocusNext()
+			if f := runloop.Window.Focused(); f != nil {
+				t.Errorf("Expected no focus, got %T", f)
+			}
+			return nil
+		})
+	})
+	t.Run("Showing Layer Invalidates Its Frame", func(t *testing.T) {
+		runloop.Do(func() error {
+			layer.SetHidden(false)
+			return nil
+		})
+		if c := pixelAt(runloop, 5, 5); c != red {
+			t.Errorf("Expected shown layer to be drawn, got %v", c)
+		}
+		runloop.Do(func() error {
+			runloop.Window.FocusNext()
+			if f := runloop.Window.Focused(); f != layer {
+				t.Errorf("Expected shown layer to be focusable, got %T", f)
+			}
+			return nil
+		})
+	})
+}
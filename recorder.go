@@ -0,0 +1,419 @@
+package touch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MinRecordedFrameTime is the shortest time a recorded frame is shown.
+// Updates made sooner are combined with the following frame, as most
+// GIF viewers slow down frames shorter than 20ms.
+const MinRecordedFrameTime = 20 * time.Millisecond
+
+var errRecorderClosed = errors.New("recorder is closed")
+
+// Recorder is a sink that records the frames of a window, with their timing,
+// as an animated GIF or a numbered sequence of PNG images. It is useful for
+// documentation, and for capturing a repro from a headless window:
+//
+//	rec := touch.NewGIFRecorder(file)
+//	window.AddSink(rec)
+//	...
+//	window.RemoveSink(rec)
+//	err := rec.Close()
+//
+// Frames are timed by the runloop's clock, as of when they were drawn, so the
+// recording keeps the timing of the UI even if encoding falls behind. Each frame
+// shows until the next one, and the last frame until Close.
+type Recorder struct {
+	// Source of timestamps for frames flushed without a frame time, and for the
+	// end of the last frame; the system clock if nil. Set it to the runloop's Clock
+	// if that is not the system clock.
+	Clock Clock
+	// Dither GIF frames with more than 256 colors, rather than mapping each
+	// pixel to the nearest color in the frame's palette.
+	Dither bool
+
+	mu      sync.Mutex
+	encoder frameEncoder
+	mirror  *image.RGBA
+	start   time.Time
+	// The region updated since the last recorded frame, and when it was first updated
+	pending   image.Rectangle
+	pendingAt time.Time
+	closed    bool
+}
+
+// frameEncoder writes recorded frames. Frames are full-size images, of which
+// only rect has changed since the previous frame. Times are relative to the first frame.
+type frameEncoder interface {
+	frame(img *image.RGBA, rect image.Rectangle, start, end time.Duration) error
+	close() error
+}
+
+// NewGIFRecorder returns a Recorder that streams an animated GIF to w.
+// Each frame holds only the region that changed, with a palette of its own,
+// and is written as soon as its duration is known; The GIF is complete after Close.
+func NewGIFRecorder(w io.Writer) *Recorder {
+	rec := &Recorder{}
+	rec.encoder = &gifEncoder{rec: rec, w: w}
+	return rec
+}
+
+// NewPNGRecorder returns a Recorder that writes each frame to dir, which is created
+// if needed, as frame-00000.png, frame-00001.png and so on. Their durations are
+// written to frames.txt in ffconcat format, so they can be made into a video:
+//
+//	ffmpeg -f concat -i frames.txt -pix_fmt yuv420p recording.mp4
+func NewPNGRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	index, err := os.Create(filepath.Join(dir, "frames.txt"))
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(index, "ffconcat version 1.0")
+	return &Recorder{encoder: &pngEncoder{dir: dir, index: index}}, nil
+}
+
+func (r *Recorder) now() time.Time {
+	if r.Clock != nil {
+		return r.Clock.Now()
+	}
+	return time.Now()
+}
+
+// Flush records the updated rects of buf, drawn now according to Clock.
+func (r *Recorder) Flush(buf *image.RGBA, rects []image.Rectangle) error {
+	return r.FlushAt(buf, rects, time.Time{})
+}
+
+// FlushAt records the updated rects of buf, drawn at frameTime. The frame is
+// written when the next frame arrives, once its duration is known.
+func (r *Recorder) FlushAt(buf *image.RGBA, rects []image.Rectangle, frameTime time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return errRecorderClosed
+	}
+
+	now := frameTime
+	if now.IsZero() {
+		now = r.now()
+	}
+	if r.mirror == nil {
+		r.mirror = image.NewRGBA(buf.Rect)
+		r.start = now
+		rects = []image.Rectangle{buf.Rect}
+	} else if !r.pending.Empty() && now.Sub(r.pendingAt) >= MinRecordedFrameTime {
+		if err := r.writePending(now); err != nil {
+			return err
+		}
+	}
+
+	for _, rect := range rects {
+		copyRect(r.mirror, buf, rect)
+		if r.pending.Empty() {
+			r.pendingAt = now
+		}
+		r.pending = r.pending.Union(rect.Intersect(r.mirror.Rect))
+	}
+	return nil
+}
+
+// writePending writes the pending frame, ending at end.
+func (r *Recorder) writePending(end time.Time) error {
+	err := r.encoder.frame(r.mirror, r.pending, r.pendingAt.Sub(r.start), end.Sub(r.start))
+	r.pending = image.Rectangle{}
+	return err
+}
+
+// Close writes the last frame, and finishes the recording.
+// Frames flushed after Close return an error.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return errRecorderClosed
+	}
+	r.closed = true
+
+	var err error
+	if !r.pending.Empty() {
+		err = r.writePending(r.now())
+	}
+	if closeErr := r.encoder.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// centiseconds rounds d to the 10ms units used by GIF frame delays.
+func centiseconds(d time.Duration) int {
+	return int((d + 5*time.Millisecond) / (10 * time.Millisecond))
+}
+
+// GIF framing, for streaming frames encoded individually by image/gif
+const (
+	// Length of the header and logical screen descriptor, with no global color table
+	gifHeaderLen = 13
+	gifTrailer   = 0x3B
+)
+
+// gifLoopForever is an application extension that makes the animation repeat.
+var gifLoopForever = []byte{
+	0x21, 0xFF, 0x0B, 'N', 'E', 'T', 'S', 'C', 'A', 'P', 'E', '2', '.', '0',
+	0x03, 0x01, 0x00, 0x00, 0x00,
+}
+
+type gifEncoder struct {
+	rec    *Recorder
+	w      io.Writer
+	frames int
+	buf    bytes.Buffer
+	// Time at which the last frame ends, rounded to centiseconds
+	end int
+	// Color counts and palette indexes, by 15-bit color
+	counts [1 << 15]int32
+	lookup [1 << 15]int16
+}
+
+// frame encodes the frame as a GIF of its own, and copies its image blocks to the
+// stream, so that frames need not be kept in memory until the recording ends.
+func (e *gifEncoder) frame(img *image.RGBA, rect image.Rectangle, start, end time.Duration) error {
+	if e.frames == 0 {
+		// The first frame covers the whole animation
+		rect = img.Rect
+	}
+	// Round the end of each frame rather than its duration, so rounding errors don't accumulate
+	frameEnd := centiseconds(end)
+	delay := frameEnd - e.end
+	e.end = frameEnd
+
+	e.buf.Reset()
+	err := gif.EncodeAll(&e.buf, &gif.GIF{
+		Image:    []*image.Paletted{e.quantize(img, rect, e.rec.Dither)},
+		Delay:    []int{delay},
+		Disposal: []byte{gif.DisposalNone},
+		Config:   image.Config{Width: img.Rect.Dx(), Height: img.Rect.Dy()},
+	})
+	if err != nil {
+		return err
+	}
+	encoded := e.buf.Bytes()
+	if e.frames == 0 {
+		if _, err := e.w.Write(encoded[:gifHeaderLen]); err != nil {
+			return err
+		}
+		if _, err := e.w.Write(gifLoopForever); err != nil {
+			return err
+		}
+	}
+	// Omit the header, and the trailer that ends the file
+	if _, err := e.w.Write(encoded[gifHeaderLen : len(encoded)-1]); err != nil {
+		return err
+	}
+	e.frames++
+	return nil
+}
+
+func (e *gifEncoder) close() error {
+	if e.frames == 0 {
+		return errors.New("no frames were recorded")
+	}
+	_, err := e.w.Write([]byte{gifTrailer})
+	return err
+}
+
+// quantize returns the pixels of img in rect, reduced to a palette of at most
+// 256 colors. Colors are counted at 5 bits per channel; if there are more than 256,
+// the palette is chosen by median cut.
+func (e *gifEncoder) quantize(img *image.RGBA, rect image.Rectangle, dither bool) *image.Paletted {
+	counts, lookup := &e.counts, &e.lookup
+	for b := range counts {
+		counts[b] = 0
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(rect.Min.X, y):img.PixOffset(rect.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			counts[colorBucket(row[i:])]++
+		}
+	}
+	var used []int
+	for b, count := range counts {
+		if count > 0 {
+			used = append(used, b)
+		}
+	}
+
+	boxes := medianCut(used, counts[:], 256)
+	palette := make(color.Palette, len(boxes))
+	for idx, box := range boxes {
+		palette[idx] = box.average(counts[:])
+		for _, b := range box.buckets {
+			lookup[b] = int16(idx)
+		}
+	}
+
+	dst := image.NewPaletted(rect, palette)
+	if dither && len(used) > len(palette) {
+		draw.FloydSteinberg.Draw(dst, rect, img, rect.Min)
+		return dst
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(rect.Min.X, y):img.PixOffset(rect.Max.X, y)]
+		out := dst.Pix[dst.PixOffset(rect.Min.X, y):]
+		for i := 0; i < len(row); i += 4 {
+			out[i/4] = uint8(lookup[colorBucket(row[i:])])
+		}
+	}
+	return dst
+}
+
+// colorBucket returns the 15-bit color of an RGBA pixel.
+func colorBucket(px []uint8) int {
+	return int(px[0]>>3)<<10 | int(px[1]>>3)<<5 | int(px[2]>>3)
+}
+
+// bucketChannel returns channel c (0 for red, 1 for green, 2 for blue) of a 15-bit color.
+func bucketChannel(b, c int) int {
+	return b >> (10 - 5*c) & 31
+}
+
+// colorBox is a set of 15-bit colors, and the channel in which they vary most.
+type colorBox struct {
+	buckets []int
+	channel int
+	spread  int
+}
+
+func newColorBox(buckets []int) colorBox {
+	box := colorBox{buckets: buckets}
+	for c := 0; c < 3; c++ {
+		lo, hi := 31, 0
+		for _, b := range buckets {
+			v := bucketChannel(b, c)
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if hi-lo > box.spread {
+			box.channel, box.spread = c, hi-lo
+		}
+	}
+	return box
+}
+
+// average returns the mean color of the box, weighted by counts.
+func (box colorBox) average(counts []int32) color.RGBA {
+	var sum [3]int
+	var total int
+	for _, b := range box.buckets {
+		n := int(counts[b])
+		for c := range sum {
+			v := bucketChannel(b, c)
+			sum[c] += (v<<3 | v>>2) * n
+		}
+		total += n
+	}
+	return color.RGBA{uint8(sum[0] / total), uint8(sum[1] / total), uint8(sum[2] / total), 0xFF}
+}
+
+// medianCut divides buckets into at most n boxes, by repeatedly splitting the box
+// with the widest spread of colors where half of its pixels fall on either side.
+func medianCut(buckets []int, counts []int32, n int) []colorBox {
+	boxes := []colorBox{newColorBox(buckets)}
+	for len(boxes) < n {
+		widest := -1
+		for idx, box := range boxes {
+			if box.spread > 0 && (widest < 0 || box.spread > boxes[widest].spread) {
+				widest = idx
+			}
+		}
+		if widest < 0 {
+			// Every box holds a single color
+			break
+		}
+		box := boxes[widest]
+		sort.Slice(box.buckets, func(i, j int) bool {
+			return bucketChannel(box.buckets[i], box.channel) < bucketChannel(box.buckets[j], box.channel)
+		})
+		var total, half int
+		for _, b := range box.buckets {
+			total += int(counts[b])
+		}
+		split := 1
+		for ; split < len(box.buckets)-1; split++ {
+			if half += int(counts[box.buckets[split-1]]); half*2 >= total {
+				break
+			}
+		}
+		boxes = append(boxes, newColorBox(box.buckets[split:]))
+		boxes[widest] = newColorBox(box.buckets[:split])
+	}
+	return boxes
+}
+
+type pngEncoder struct {
+	dir   string
+	index *os.File
+	count int
+	last  string
+}
+
+func (e *pngEncoder) frame(img *image.RGBA, rect image.Rectangle, start, end time.Duration) error {
+	name := fmt.Sprintf("frame-%05d.png", e.count)
+	file, err := os.Create(filepath.Join(e.dir, name))
+	if err != nil {
+		return err
+	}
+	err = png.Encode(file, opaqueImage{img})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	e.count++
+	e.last = name
+	_, err = fmt.Fprintf(e.index, "file '%s'\nduration %.3f\n", name, (end - start).Seconds())
+	return err
+}
+
+func (e *pngEncoder) close() error {
+	var err error
+	if e.last != "" {
+		// ffmpeg ignores the duration of the last entry, unless the file is repeated
+		_, err = fmt.Fprintf(e.index, "file '%s'\n", e.last)
+	}
+	if closeErr := e.index.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// opaqueImage presents an RGBA image as fully opaque, since transparent areas
+// of the window buffer are shown as black on the display.
+type opaqueImage struct {
+	*image.RGBA
+}
+
+func (img opaqueImage) Opaque() bool {
+	return true
+}
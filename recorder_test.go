@@ -0,0 +1,146 @@
+package touch_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+func TestRecorder(t *testing.T) {
+	red := color.RGBA{0xFF, 0, 0, 0xFF}
+	blue := color.RGBA{0, 0, 0xFF, 0xFF}
+	start := time.Unix(0, 0)
+
+	buf := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	draw.Draw(buf, buf.Rect, image.NewUniform(red), image.Point{}, draw.Src)
+
+	t.Run("GIF Frames And Delays", func(t *testing.T) {
+		var out bytes.Buffer
+		clock := touch.NewManualClock(start)
+		rec := touch.NewGIFRecorder(&out)
+		rec.Clock = clock
+
+		frame := image.NewRGBA(buf.Rect)
+		copy(frame.Pix, buf.Pix)
+		rec.FlushAt(frame, []image.Rectangle{buf.Rect}, start)
+		patch := image.Rect(4, 2, 8, 6)
+		draw.Draw(frame, patch, image.NewUniform(blue), image.Point{}, draw.Src)
+		rec.FlushAt(frame, []image.Rectangle{patch}, start.Add(100*time.Millisecond))
+		// Updates sooner than MinRecordedFrameTime are combined into one frame
+		draw.Draw(frame, image.Rect(9, 2, 10, 3), image.NewUniform(blue), image.Point{}, draw.Src)
+		rec.FlushAt(frame, []image.Rectangle{image.Rect(9, 2, 10, 3)}, start.Add(105*time.Millisecond))
+		clock.Advance(354 * time.Millisecond)
+		if err := rec.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		anim, err := gif.DecodeAll(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if anim.Config.Width != 16 || anim.Config.Height != 8 || anim.LoopCount != 0 {
+			t.Errorf("Unexpected config %+v, loop count %d", anim.Config, anim.LoopCount)
+		}
+		if len(anim.Image) != 2 {
+			t.Fatalf("Expected 2 frames, got %d", len(anim.Image))
+		}
+		if anim.Delay[0] != 10 || anim.Delay[1] != 25 {
+			t.Errorf("Expected delays of 10 and 25 centiseconds, got %v", anim.Delay)
+		}
+		if r := anim.Image[1].Rect; r != image.Rect(4, 2, 10, 6) {
+			t.Errorf("Expected the second frame to cover the combined updates, got %v", r)
+		}
+		if c := anim.Image[1].At(5, 3); c != color.Color(blue) {
+			t.Errorf("Expected blue in the second frame, got %v", c)
+		}
+		// Palettes are padded to a power of two
+		if p := anim.Image[0].Palette; len(p) != 2 || p[0] != color.Color(red) {
+			t.Errorf("Expected a palette of one red color, got %v", p)
+		}
+	})
+	t.Run("GIF Palette Quantization", func(t *testing.T) {
+		gradient := image.NewRGBA(image.Rect(0, 0, 64, 64))
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				gradient.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), 0x80, 0xFF})
+			}
+		}
+		var out bytes.Buffer
+		rec := touch.NewGIFRecorder(&out)
+		rec.FlushAt(gradient, []image.Rectangle{gradient.Rect}, start)
+		rec.Close()
+
+		anim, err := gif.DecodeAll(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frame := anim.Image[0]
+		if len(frame.Palette) != 256 {
+			t.Errorf("Expected a full palette for 4096 colors, got %d", len(frame.Palette))
+		}
+		// Each of the 256 colors stands for about 16 of the originals
+		var worst int
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				want := gradient.RGBAAt(x, y)
+				got := color.RGBAModel.Convert(frame.At(x, y)).(color.RGBA)
+				for _, d := range []int{int(want.R) - int(got.R), int(want.G) - int(got.G), int(want.B) - int(got.B)} {
+					if d < 0 {
+						d = -d
+					}
+					if d > worst {
+						worst = d
+					}
+				}
+			}
+		}
+		if worst > 24 {
+			t.Errorf("Expected quantized colors near the originals, worst channel error %d", worst)
+		}
+	})
+	t.Run("PNG Sequence", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "frames")
+		clock := touch.NewManualClock(start)
+		rec, err := touch.NewPNGRecorder(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec.Clock = clock
+		rec.FlushAt(buf, []image.Rectangle{buf.Rect}, start)
+		rec.FlushAt(buf, []image.Rectangle{image.Rect(0, 0, 1, 1)}, start.Add(250*time.Millisecond))
+		clock.Advance(time.Second)
+		if err := rec.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range []string{"frame-00000.png", "frame-00001.png"} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				t.Error(err)
+			}
+		}
+		index, _ := os.ReadFile(filepath.Join(dir, "frames.txt"))
+		expected := "ffconcat version 1.0\n" +
+			"file 'frame-00000.png'\nduration 0.250\n" +
+			"file 'frame-00001.png'\nduration 0.750\n" +
+			"file 'frame-00001.png'\n"
+		if string(index) != expected {
+			t.Errorf("Unexpected frames.txt:\n%s", index)
+		}
+	})
+	t.Run("Flush After Close Fails", func(t *testing.T) {
+		rec := touch.NewGIFRecorder(&bytes.Buffer{})
+		rec.Flush(buf, []image.Rectangle{buf.Rect})
+		rec.Close()
+		if err := rec.Flush(buf, []image.Rectangle{buf.Rect}); err == nil {
+			t.Error("Expected an error after Close")
+		}
+	})
+}
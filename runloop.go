@@ -1,6 +1,11 @@
 package touch
 
-import "context"
+import (
+	"context"
+	"image"
+	"sync"
+	"time"
+)
 
 var (
 	MainRunLoop RunLoop
@@ -8,68 +13,186 @@ var (
 
 type RunLoop struct {
 	Window *Window
-	Tasks  chan<- func()
-	tasks  chan func()
-	events chan TouchEvent
+	// Tasks runs functions on the runloop. Sends block when the channel is full;
+	// Post, Do, and DoAsync do not.
+	Tasks chan<- func()
+	// Input configures the devices read by the runloop, and must be set before Init.
+	Input EventStream
+	// InputDeviceChanged, if set, is called on the runloop when an input device
+	// is connected or lost. Lost devices are reopened automatically.
+	InputDeviceChanged func(InputDeviceEvent)
+	// Clock drives timers scheduled with After and Every. Defaults to the system clock.
+	Clock Clock
+	// MaxFPS limits how often the display is updated; Zero means no limit.
+	// Invalidations between frames are coalesced into the next frame.
+	MaxFPS int
+	// ImmediateFirstFrame draws the first frame after an idle period right away,
+	// instead of waiting one frame for more invalidations to coalesce.
+	ImmediateFirstFrame bool
+	// PanicHandler, if set, is called on the runloop when a task panics.
+	// By default, the panic and its stack are written to stderr.
+	PanicHandler func(*PanicError)
+
+	tasks    chan func()
+	headless bool
+
+	// Tasks queued with Post, and a signal that the queue is nonempty
+	queueMu sync.Mutex
+	queue   []queuedTask
+	wakeCh  chan struct{}
+	// Set when Run returns, and cleared when it starts
+	stopped bool
+
+	// Frame pacing state
+	lastFrame      time.Time
+	frameTimer     *Timer
+	frameCallbacks []*FrameCallback
+
+	// State of the touch in progress
+	touchTarget   LayerTouchDelegate
+	touchCanceled bool
 }
 
+// Init prepares the runloop to drive window. Each window must have its own runloop.
+// MainRunLoop is the only runloop that may use a native macOS window, but any
+// number of runloops may be used with headless displays.
 func (runloop *RunLoop) Init(window *Window) {
-	if runloop != &MainRunLoop {
-		panic("Only the main RunLoop may be Initialized")
-	}
 	runloop.tasks = make(chan func(), 100)
 	runloop.Tasks = runloop.tasks
+	runloop.wakeCh = make(chan struct{}, 1)
 	runloop.Window = window
+	runloop.headless = window.display.IsHeadless()
+	window.runloop = runloop
+	runloop.Input.Init()
+	runloop.Input.SetPointerBounds(image.Rectangle{Max: window.display.Size})
 	runloop.platformInit()
 }
 
 func (runloop *RunLoop) runInner(ctx context.Context) {
-	var eventTarget LayerTouchDelegate
-	var touchCanceled bool
-	cancelTouch := func() {
-		touchCanceled = true
-		if eventTarget != nil {
-			eventTarget.CancelTouch()
-			eventTarget = nil
-		}
-	}
-
 	win := runloop.Window
-	runloop.updateDisplay()
+	runloop.queueMu.Lock()
+	runloop.stopped = false
+	runloop.queueMu.Unlock()
+	runloop.drawFrame()
 
 outer:
 	for {
 		select {
-		case event := <-runloop.events:
+		case event := <-runloop.Input.Events:
 			win.Calibrate(&event)
-			event.Cancel = cancelTouch
-			if touchCanceled {
-				// Ignore events until mouseup
-				if !event.Pressed {
-					touchCanceled = false
-				}
-			} else if event.Pressed {
-				if eventTarget != nil {
-					eventTarget.UpdateTouch(event)
-				} else {
-					// Only when there is no current event target, hit test for one.
-					if eventTarget = win.HitTest(event); eventTarget != nil {
-						eventTarget.StartTouch(event)
-					}
-				}
-			} else {
-				if eventTarget != nil {
-					eventTarget.EndTouch(event)
-					eventTarget = nil
-				}
+			runloop.dispatchTouch(event)
+		case event := <-runloop.Input.Pointer:
+			win.MoveCursor(event.Point)
+			runloop.dispatchTouch(event)
+		case key := <-runloop.Input.Keys:
+			win.HandleKey(key)
+		case device := <-runloop.Input.Devices:
+			if handler := runloop.InputDeviceChanged; handler != nil {
+				handler(device)
 			}
 		case task := <-runloop.tasks:
-			task()
+			runloop.runTask(task)
+		case <-runloop.wakeCh:
+			runloop.drainQueue()
 		case <-win.redrawCh:
-			runloop.updateDisplay()
+			runloop.requestFrame()
 		case <-ctx.Done():
 			runloop.cleanup()
+			runloop.stop()
 			break outer
 		}
 	}
 }
+
+// requestFrame updates the display now, or schedules an update within the frame budget.
+func (runloop *RunLoop) requestFrame() {
+	if runloop.frameTimer != nil {
+		// The pending frame will include this update
+		return
+	}
+	budget := runloop.frameBudget()
+	if budget <= 0 {
+		runloop.drawFrame()
+		return
+	}
+
+	wait := runloop.lastFrame.Add(budget).Sub(runloop.clock().Now())
+	if wait <= 0 {
+		// The display has been idle for at least one frame
+		if runloop.ImmediateFirstFrame {
+			runloop.drawFrame()
+			return
+		}
+		wait = budget
+	}
+	runloop.frameTimer = runloop.After(wait, runloop.drawFrame)
+}
+
+// frameBudget returns the minimum time between frames, set by MaxFPS or the
+// slowest SlowSink, or 0 if frames may be drawn as often as needed.
+func (runloop *RunLoop) frameBudget() time.Duration {
+	var budget time.Duration
+	if runloop.MaxFPS > 0 {
+		budget = time.Second / time.Duration(runloop.MaxFPS)
+	}
+	for _, worker := range runloop.Window.sinks {
+		if slow, ok := worker.sink.(SlowSink); ok {
+			if interval := slow.MinFrameInterval(); interval > budget {
+				budget = interval
+			}
+		}
+	}
+	return budget
+}
+
+func (runloop *RunLoop) drawFrame() {
+	runloop.frameTimer = nil
+	runloop.lastFrame = runloop.clock().Now()
+	runloop.runFrameCallbacks(runloop.lastFrame)
+	runloop.updateDisplay()
+
+	// Keep ticking while there are frame callbacks
+	if len(runloop.frameCallbacks) > 0 && runloop.frameTimer == nil {
+		runloop.frameTimer = runloop.After(runloop.frameWait(), runloop.drawFrame)
+	}
+}
+
+func (runloop *RunLoop) clock() Clock {
+	if runloop.Clock == nil {
+		return systemClock{}
+	}
+	return runloop.Clock
+}
+
+// dispatchTouch delivers a touch event, in screen coordinates, to the layer being touched.
+func (runloop *RunLoop) dispatchTouch(event TouchEvent) {
+	event.Cancel = runloop.cancelTouch
+	if runloop.touchCanceled {
+		// Ignore events until mouseup
+		if !event.Pressed {
+			runloop.touchCanceled = false
+		}
+	} else if event.Pressed {
+		if runloop.touchTarget != nil {
+			runloop.touchTarget.UpdateTouch(event)
+		} else {
+			// Only when there is no current event target, hit test for one.
+			if runloop.touchTarget = runloop.Window.HitTest(event); runloop.touchTarget != nil {
+				runloop.touchTarget.StartTouch(event)
+			}
+		}
+	} else {
+		if runloop.touchTarget != nil {
+			runloop.touchTarget.EndTouch(event)
+			runloop.touchTarget = nil
+		}
+	}
+}
+
+func (runloop *RunLoop) cancelTouch() {
+	runloop.touchCanceled = true
+	if runloop.touchTarget != nil {
+		runloop.touchTarget.CancelTouch()
+		runloop.touchTarget = nil
+	}
+}
@@ -23,7 +23,7 @@ func init() {
 
 //export receiveMouseEvent
 func receiveMouseEvent(x, y int, pressed bool) {
-	MainRunLoop.events <- TouchEvent{
+	MainRunLoop.Input.Events <- TouchEvent{
 		Point:    image.Point{X: x, Y: y},
 		Pressed:  pressed,
 		Pressure: 0xFF,
@@ -31,7 +31,12 @@ func receiveMouseEvent(x, y int, pressed bool) {
 }
 
 func (runloop *RunLoop) platformInit() {
-	runloop.events = make(chan TouchEvent, 100)
+	if runloop.headless {
+		return
+	}
+	if runloop != &MainRunLoop {
+		panic("Only the main RunLoop may use a native window")
+	}
 
 	window := runloop.Window
 	// Don't allow round corners for windowed display
@@ -43,22 +48,30 @@ func (runloop *RunLoop) platformInit() {
 
 func (runloop *RunLoop) updateDisplay() {
 	win := runloop.Window
+	if runloop.headless {
+		win.update(func(_ *image.RGBA, _ []image.Rectangle) int { return 0 })
+		return
+	}
 	cW, cH := C.int(win.display.Size.X), C.int(win.display.Size.Y)
 
-	dirty := false
-	win.update(func(_ *image.RGBA) {
-		dirty = true
+	win.update(func(frame *image.RGBA, _ []image.Rectangle) int {
+		// The window is redrawn from the whole frame, whichever rects changed
+		C.DrawRGBA(unsafe.Pointer(&frame.Pix[0]), C.int(len(frame.Pix)), cW, cH)
+		return len(frame.Pix) / 4
 	})
-	if dirty {
-		C.DrawRGBA(unsafe.Pointer(&win.RGBA.Pix[0]), C.int(len(win.RGBA.Pix)), cW, cH)
-	}
 }
 
 func (runloop *RunLoop) cleanup() {
-	C.StopApp()
+	if !runloop.headless {
+		C.StopApp()
+	}
 }
 
 func (runloop *RunLoop) Run(ctx context.Context) {
+	if runloop.headless {
+		runloop.runInner(ctx)
+		return
+	}
 	go runloop.runInner(ctx)
 	C.RunApp()
 }
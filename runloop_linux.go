@@ -2,31 +2,49 @@ package touch
 
 import (
 	"context"
-	"os"
+	"image"
 )
 
 func (runloop *RunLoop) platformInit() {
-	// For Linux, open the eventfile and start reading it.
-	eventFile, err := os.Open("/dev/input/event0")
-	if err != nil {
-		panic(err)
-	}
+	// Input devices are opened by Run, so that they are only grabbed while it runs
+}
 
-	var e EventStream
-	e.Init()
-	runloop.events = e.Events
-	go e.inputReadLoop(eventFile)
+// watchDevices reads each eventfile until ctx is done, reopening them if they are lost.
+func (runloop *RunLoop) watchDevices(ctx context.Context) {
+	paths := runloop.Input.Paths
+	if len(paths) == 0 && !runloop.headless {
+		// The default touchscreen accompanies the framebuffer
+		paths = []string{"/dev/input/event0"}
+	}
+	for _, path := range paths {
+		go runloop.Input.watchDevice(ctx, path)
+	}
 }
 
 func (runloop *RunLoop) updateDisplay() {
 	win := runloop.Window
-	win.update(win.display.render)
+	win.update(func(frame *image.RGBA, rects []image.Rectangle) (converted int) {
+		if runloop.headless {
+			return 0
+		}
+		for _, rect := range rects {
+			win.display.render(frame.SubImage(rect).(*image.RGBA))
+			converted += rect.Dx() * rect.Dy()
+		}
+		return
+	})
 }
 
 func (runloop *RunLoop) cleanup() {
-	// No additional cleanup on Linux
+	// Release input devices, including any exclusive grabs
+	runloop.Input.Close()
 }
 
 func (runloop *RunLoop) Run(ctx context.Context) {
+	// The devices closed by an earlier Run are watched afresh, and stop being watched when Run returns
+	runloop.Input.reopen()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	runloop.watchDevices(ctx)
 	runloop.runInner(ctx)
 }
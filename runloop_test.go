@@ -0,0 +1,158 @@
+package touch_test
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+// slowSink is a SlowSink that records its frames.
+type slowSink struct {
+	*recordingSink
+	interval time.Duration
+}
+
+func (s *slowSink) MinFrameInterval() time.Duration {
+	return s.interval
+}
+
+// framePacer runs a headless window on a manual clock, and records the time of each frame.
+type framePacer struct {
+	runloop *touch.RunLoop
+	clock   *touch.ManualClock
+	frames  []time.Time
+}
+
+func startFramePacer(t *testing.T, configure func(*touch.RunLoop)) *framePacer {
+	p := &framePacer{clock: touch.NewManualClock(time.Unix(0, 0))}
+	p.runloop = startHeadless(t, p.clock)
+	p.runloop.Do(func() error {
+		configure(p.runloop)
+		p.runloop.Window.OnFrame = func(touch.FrameStats) {
+			p.frames = append(p.frames, p.clock.Now())
+		}
+		return nil
+	})
+	p.settle()
+	return p
+}
+
+// settle waits until the runloop has scheduled any frame requested by the window.
+func (p *framePacer) settle() {
+	for pending := true; pending; {
+		p.runloop.Do(func() error {
+			pending = p.runloop.Window.RedrawPending()
+			return nil
+		})
+	}
+}
+
+// invalidate marks part of the window as needing display, and waits for a frame to be scheduled.
+func (p *framePacer) invalidate() {
+	p.runloop.Do(func() error {
+		p.runloop.Window.InvalidateRect(image.Rect(0, 0, 4, 4))
+		return nil
+	})
+	p.settle()
+}
+
+// advance moves the clock forward, and waits for any frames that became due.
+func (p *framePacer) advance(d time.Duration) {
+	p.clock.Advance(d)
+	waitForTasks(p.runloop)
+	p.settle()
+}
+
+// expectFrames checks the times of all frames so far, as offsets from the start of the clock.
+func (p *framePacer) expectFrames(t *testing.T, offsets ...time.Duration) {
+	t.Helper()
+	var frames []time.Time
+	p.runloop.Do(func() error {
+		frames = append(frames, p.frames...)
+		return nil
+	})
+	ok := len(frames) == len(offsets)
+	for idx := 0; ok && idx < len(offsets); idx++ {
+		ok = frames[idx].Equal(time.Unix(0, 0).Add(offsets[idx]))
+	}
+	if !ok {
+		t.Errorf("Expected frames at %v, got %v", offsets, frames)
+	}
+}
+
+func TestFramePacing(t *testing.T) {
+	const ms = time.Millisecond
+
+	t.Run("Unlimited Frames Are Immediate", func(t *testing.T) {
+		p := startFramePacer(t, func(*touch.RunLoop) {})
+		p.invalidate()
+		p.expectFrames(t, 0)
+		p.advance(10 * ms)
+		p.invalidate()
+		p.expectFrames(t, 0, 10*ms)
+	})
+	t.Run("Invalidations Within A Budget Are Coalesced", func(t *testing.T) {
+		p := startFramePacer(t, func(runloop *touch.RunLoop) {
+			runloop.MaxFPS = 10
+		})
+		p.advance(time.Second)
+		for idx := 0; idx < 4; idx++ {
+			p.invalidate()
+			p.advance(20 * ms)
+		}
+		p.expectFrames(t)
+		p.advance(20 * ms)
+		p.expectFrames(t, 1100*ms)
+		p.advance(time.Second)
+		p.expectFrames(t, 1100*ms)
+	})
+	t.Run("First Frame Waits One Budget By Default", func(t *testing.T) {
+		p := startFramePacer(t, func(runloop *touch.RunLoop) {
+			runloop.MaxFPS = 10
+		})
+		p.advance(time.Second)
+		p.invalidate()
+		p.advance(99 * ms)
+		p.expectFrames(t)
+		p.advance(1 * ms)
+		p.expectFrames(t, 1100*ms)
+	})
+	t.Run("Immediate First Frame", func(t *testing.T) {
+		p := startFramePacer(t, func(runloop *touch.RunLoop) {
+			runloop.MaxFPS = 10
+			runloop.ImmediateFirstFrame = true
+		})
+		p.advance(time.Second)
+		p.invalidate()
+		p.expectFrames(t, time.Second)
+
+		// Frames that follow it are still paced by the budget
+		p.advance(30 * ms)
+		p.invalidate()
+		p.expectFrames(t, time.Second)
+		p.advance(69 * ms)
+		p.expectFrames(t, time.Second)
+		p.advance(1 * ms)
+		p.expectFrames(t, time.Second, 1100*ms)
+	})
+	t.Run("Slow Sink Sets The Budget", func(t *testing.T) {
+		sink := &slowSink{recordingSink: newRecordingSink(nil), interval: 500 * ms}
+		p := startFramePacer(t, func(runloop *touch.RunLoop) {
+			runloop.MaxFPS = 60
+			runloop.ImmediateFirstFrame = true
+			runloop.Window.AddSink(sink)
+		})
+		p.advance(time.Second)
+		p.invalidate()
+		p.expectFrames(t, time.Second)
+
+		// The sink's interval is longer than the frame budget of MaxFPS, and so holds the next frame back
+		p.invalidate()
+		p.advance(499 * ms)
+		p.expectFrames(t, time.Second)
+		p.advance(1 * ms)
+		p.expectFrames(t, time.Second, 1500*ms)
+	})
+}
@@ -0,0 +1,207 @@
+package touch
+
+import (
+	"context"
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// DefaultSimulatorAddr is the address Simulator listens on if Addr is empty.
+const DefaultSimulatorAddr = "localhost:8080"
+
+//go:embed simulator.html
+var simulatorPage []byte
+
+// Simulator shows a window in web browsers, and delivers their mouse, touch
+// and keyboard input to the runloop. To develop without a device, initialize
+// a headless display and add a Simulator to its window:
+//
+//	display.InitHeadless(320, 480)
+//	sim := touch.NewSimulator(&touch.MainRunLoop)
+//	window.Init(display)
+//	window.AddSink(sim)
+//	...
+//	go sim.ListenAndServe(ctx)
+//
+// Updated regions are streamed to the page over a WebSocket as they are drawn.
+type Simulator struct {
+	// Address to listen on; DefaultSimulatorAddr if empty.
+	Addr string
+
+	runloop *RunLoop
+	mu      sync.Mutex
+	clients map[*simClient]struct{}
+}
+
+type simClient struct {
+	ws     *wsConn
+	frames chan []byte
+}
+
+// Updates queued for a client that isn't keeping up. Once exceeded, the client
+// is disconnected; the page reconnects and receives a full frame.
+const simClientBacklog = 64
+
+// simInput is a JSON message from the page.
+type simInput struct {
+	Type   string // "touch" or "key"
+	X, Y   int
+	Key    Key
+	Down   bool
+	Repeat bool
+}
+
+// NewSimulator returns a Simulator for runloop.
+func NewSimulator(runloop *RunLoop) *Simulator {
+	return &Simulator{
+		runloop: runloop,
+		clients: map[*simClient]struct{}{},
+	}
+}
+
+// Flush sends the updated rects of buf to every connected page.
+func (s *Simulator) Flush(buf *image.RGBA, rects []image.Rectangle) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.clients) == 0 {
+		return nil
+	}
+
+	for _, rect := range rects {
+		msg := encodeSimFrame(buf, rect)
+		for client := range s.clients {
+			select {
+			case client.frames <- msg:
+			default:
+				// Closing the connection ends the client's read loop, which unregisters it
+				client.ws.Close()
+				delete(s.clients, client)
+			}
+		}
+	}
+	return nil
+}
+
+// encodeSimFrame encodes the pixels of rect as the page expects them:
+// X, Y, width and height as big-endian uint16s, followed by RGBA rows.
+func encodeSimFrame(buf *image.RGBA, rect image.Rectangle) []byte {
+	rect = rect.Intersect(buf.Rect)
+	w, h := rect.Dx(), rect.Dy()
+	msg := make([]byte, 8, 8+4*w*h)
+	binary.BigEndian.PutUint16(msg[0:], uint16(rect.Min.X))
+	binary.BigEndian.PutUint16(msg[2:], uint16(rect.Min.Y))
+	binary.BigEndian.PutUint16(msg[4:], uint16(w))
+	binary.BigEndian.PutUint16(msg[6:], uint16(h))
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		offset := buf.PixOffset(rect.Min.X, y)
+		msg = append(msg, buf.Pix[offset:offset+4*w]...)
+	}
+	return msg
+}
+
+// Handler returns the simulator page and its WebSocket, for mounting on an existing server.
+func (s *Simulator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(simulatorPage)
+	})
+	mux.HandleFunc("/socket", s.serveSocket)
+	return mux
+}
+
+// ListenAndServe serves the simulator until ctx is done.
+func (s *Simulator) ListenAndServe(ctx context.Context) error {
+	addr := s.Addr
+	if addr == "" {
+		addr = DefaultSimulatorAddr
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return serveUntilDone(ctx, listener, s.Handler())
+}
+
+func (s *Simulator) serveSocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	client := &simClient{ws: ws, frames: make(chan []byte, simClientBacklog)}
+	var size []byte
+	// Queue the whole window and add the client in one runloop task, so that the
+	// browser's first frame is complete, and every later frame follows it.
+	err = s.runloop.Do(func() error {
+		buf := s.runloop.Window.RGBA
+		size, _ = json.Marshal(map[string]int{"width": buf.Rect.Dx(), "height": buf.Rect.Dy()})
+		client.frames <- encodeSimFrame(buf, buf.Rect)
+
+		s.mu.Lock()
+		s.clients[client] = struct{}{}
+		s.mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, client)
+		s.mu.Unlock()
+	}()
+
+	if ws.WriteMessage(wsText, size) != nil {
+		return
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case msg := <-client.frames:
+				if ws.WriteMessage(wsBinary, msg) != nil {
+					ws.Close()
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		opcode, msg, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var input simInput
+		if opcode == wsText && json.Unmarshal(msg, &input) == nil {
+			s.deliver(input)
+		}
+	}
+}
+
+func (s *Simulator) deliver(input simInput) {
+	switch input.Type {
+	case "touch":
+		s.runloop.InjectTouch(TouchEvent{
+			Point:    image.Pt(input.X, input.Y),
+			Pressed:  input.Down,
+			Pressure: 0xFF,
+		})
+	case "key":
+		s.runloop.InjectKey(KeyEvent{Key: input.Key, Pressed: input.Down, Repeat: input.Repeat})
+	}
+}
@@ -0,0 +1,309 @@
+package touch
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"sync"
+	"time"
+)
+
+// DisplaySink receives the regions of the window updated by each frame.
+// Add sinks to a window with Window.AddSink.
+type DisplaySink interface {
+	// Flush is called with a copy of the window's pixels and the rects that
+	// changed since the last call. Each sink is flushed on its own goroutine,
+	// and buf belongs to the sink until Flush returns.
+	Flush(buf *image.RGBA, rects []image.Rectangle) error
+}
+
+// SlowSink is implemented by sinks that take a long time to show each frame,
+// such as e-paper panels. The runloop waits at least MinFrameInterval between
+// frames, so that updates made in the meantime are drawn together.
+type SlowSink interface {
+	DisplaySink
+	MinFrameInterval() time.Duration
+}
+
+// TimedSink is implemented by sinks that need to know when each frame was drawn,
+// such as recorders. FlushAt is called instead of Flush, with the time of the most
+// recent frame included in rects, according to the runloop's clock.
+type TimedSink interface {
+	DisplaySink
+	FlushAt(buf *image.RGBA, rects []image.Rectangle, frameTime time.Time) error
+}
+
+// sinkWorker flushes frames to a sink from its own goroutine, so that a slow sink
+// never delays the runloop or other sinks. Frames that arrive while the sink is
+// busy are combined into the next flush.
+type sinkWorker struct {
+	sink DisplaySink
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+
+	mu        sync.Mutex
+	mirror    *image.RGBA // Written by the runloop
+	pending   RegionList
+	frameTime time.Time   // Time of the most recent frame in mirror
+	front     *image.RGBA // Passed to Flush
+}
+
+func newSinkWorker(sink DisplaySink) *sinkWorker {
+	return &sinkWorker{
+		sink: sink,
+		wake: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// queue copies the pixels in rects to the worker's mirror, to be flushed with the next frame.
+func (sw *sinkWorker) queue(buf *image.RGBA, rects []image.Rectangle, frameTime time.Time) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.frameTime = frameTime
+	if sw.mirror == nil || sw.mirror.Rect != buf.Rect {
+		sw.mirror = image.NewRGBA(buf.Rect)
+		rects = []image.Rectangle{buf.Rect}
+	}
+	for _, rect := range rects {
+		copyRect(sw.mirror, buf, rect)
+		sw.pending.AddRect(rect)
+	}
+}
+
+// push queues rects and wakes the worker.
+func (sw *sinkWorker) push(buf *image.RGBA, rects []image.Rectangle, frameTime time.Time) {
+	sw.queue(buf, rects, frameTime)
+	select {
+	case sw.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run flushes queued frames until the worker is stopped, or the sink fails.
+// Frames queued before the worker is stopped are flushed before it exits.
+func (sw *sinkWorker) run(failed func(error)) {
+	defer close(sw.done)
+	for {
+		stopped := false
+		select {
+		case <-sw.stop:
+			stopped = true
+		case <-sw.wake:
+		}
+
+		sw.mu.Lock()
+		rects := append([]image.Rectangle(nil), sw.pending.Dequeue()...)
+		frameTime := sw.frameTime
+		if len(rects) > 0 && (sw.front == nil || sw.front.Rect != sw.mirror.Rect) {
+			sw.front = image.NewRGBA(sw.mirror.Rect)
+		}
+		for _, rect := range rects {
+			copyRect(sw.front, sw.mirror, rect)
+		}
+		sw.mu.Unlock()
+
+		if len(rects) > 0 {
+			if err := sw.flush(rects, frameTime); err != nil {
+				failed(err)
+				return
+			}
+		}
+		if stopped {
+			return
+		}
+	}
+}
+
+// flush calls the sink's Flush, reporting a panic as an error.
+func (sw *sinkWorker) flush(rects []image.Rectangle, frameTime time.Time) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	if timed, ok := sw.sink.(TimedSink); ok {
+		return timed.FlushAt(sw.front, rects, frameTime)
+	}
+	return sw.sink.Flush(sw.front, rects)
+}
+
+// copyRect copies the pixels of src in rect to the same location in dst.
+func copyRect(dst, src *image.RGBA, rect image.Rectangle) {
+	rect = rect.Intersect(dst.Rect).Intersect(src.Rect)
+	if rect.Empty() {
+		return
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		copy(dst.Pix[dst.PixOffset(rect.Min.X, y):dst.PixOffset(rect.Max.X, y)],
+			src.Pix[src.PixOffset(rect.Min.X, y):src.PixOffset(rect.Max.X, y)])
+	}
+}
+
+// AddSink starts sending each frame to sink, beginning with the window's current
+// contents. Sinks are flushed independently, after the frame is flushed to the
+// display, and a sink whose Flush returns an error is removed from the window.
+// AddSink must be called on the runloop, or before it runs.
+func (w *Window) AddSink(sink DisplaySink) {
+	worker := newSinkWorker(sink)
+	if w.runloop != nil && !w.runloop.lastFrame.IsZero() {
+		worker.push(w.Buffer.RGBA, []image.Rectangle{w.Buffer.Rect}, w.runloop.lastFrame)
+	} else {
+		// Wait for the first frame, rather than flushing an empty buffer
+		worker.queue(w.Buffer.RGBA, []image.Rectangle{w.Buffer.Rect}, time.Time{})
+	}
+	w.sinks = append(w.sinks, worker)
+	go worker.run(func(err error) {
+		w.sinkFailed(sink, err)
+	})
+}
+
+// sinkFailed removes a sink whose Flush failed, and reports err on the runloop.
+// Windows without a runloop report the error from the sink's goroutine.
+func (w *Window) sinkFailed(sink DisplaySink, err error) {
+	report := func() {
+		if w.OnSinkError != nil {
+			w.OnSinkError(sink, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Display sink %T removed: %v\n", sink, err)
+		}
+	}
+	if w.runloop == nil {
+		report()
+		return
+	}
+	w.runloop.Post(func() {
+		w.RemoveSink(sink)
+		report()
+	})
+}
+
+// RemoveSink stops sending frames to sink. Frames that were already drawn are
+// still flushed to it; the returned channel is closed once they have been, so
+// that the sink may be closed safely.
+func (w *Window) RemoveSink(sink DisplaySink) <-chan struct{} {
+	for idx, worker := range w.sinks {
+		if worker.sink == sink {
+			close(worker.stop)
+			w.sinks = append(w.sinks[:idx], w.sinks[idx+1:]...)
+			return worker.done
+		}
+	}
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+// Sinks returns the sinks added to the window.
+func (w *Window) Sinks() []DisplaySink {
+	sinks := make([]DisplaySink, len(w.sinks))
+	for idx, worker := range w.sinks {
+		sinks[idx] = worker.sink
+	}
+	return sinks
+}
+
+// flushSinks sends the updated rects of frame, drawn at frameTime, to every sink.
+func (w *Window) flushSinks(frame *image.RGBA, rects []image.Rectangle, frameTime time.Time) {
+	for _, worker := range w.sinks {
+		worker.push(frame, rects, frameTime)
+	}
+}
+
+// rotatedSink rotates frames clockwise before passing them to its sink.
+type rotatedSink struct {
+	sink    DisplaySink
+	quarter int
+	buf     *image.RGBA
+	rects   []image.Rectangle
+}
+
+// RotateSink returns a sink that rotates each frame clockwise by 90, 180 or 270
+// degrees before passing it to sink, for mirrors mounted at a different angle
+// to the primary display. Rotations of 90 and 270 swap the width and height.
+func RotateSink(sink DisplaySink, rotation int) (DisplaySink, error) {
+	if rotation%90 != 0 {
+		return nil, fmt.Errorf("unsupported sink rotation %d", rotation)
+	}
+	quarter := (rotation / 90) & 3
+	if quarter == 0 {
+		return sink, nil
+	}
+	return &rotatedSink{sink: sink, quarter: quarter}, nil
+}
+
+// MinFrameInterval passes through the interval of a SlowSink, or returns 0.
+func (rs *rotatedSink) MinFrameInterval() time.Duration {
+	if slow, ok := rs.sink.(SlowSink); ok {
+		return slow.MinFrameInterval()
+	}
+	return 0
+}
+
+func (rs *rotatedSink) Flush(buf *image.RGBA, rects []image.Rectangle) error {
+	rs.rotate(buf, rects)
+	return rs.sink.Flush(rs.buf, rs.rects)
+}
+
+// FlushAt passes frame times through to a TimedSink.
+func (rs *rotatedSink) FlushAt(buf *image.RGBA, rects []image.Rectangle, frameTime time.Time) error {
+	timed, ok := rs.sink.(TimedSink)
+	if !ok {
+		return rs.Flush(buf, rects)
+	}
+	rs.rotate(buf, rects)
+	return timed.FlushAt(rs.buf, rs.rects, frameTime)
+}
+
+// rotate copies rects of buf into the rotated buffer, and sets rs.rects to their rotated areas.
+func (rs *rotatedSink) rotate(buf *image.RGBA, rects []image.Rectangle) {
+	size := buf.Rect.Size()
+	if rs.quarter&1 != 0 {
+		size.X, size.Y = size.Y, size.X
+	}
+	if rs.buf == nil || rs.buf.Rect.Size() != size {
+		rs.buf = image.NewRGBA(image.Rectangle{Max: size})
+	}
+	rs.rects = rs.rects[:0]
+	for _, rect := range rects {
+		rect = rect.Intersect(buf.Rect).Sub(buf.Rect.Min)
+		if rect.Empty() {
+			continue
+		}
+		rs.rects = append(rs.rects, rs.rotateRect(rect, buf.Rect.Size()))
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			row := buf.Pix[buf.PixOffset(rect.Min.X+buf.Rect.Min.X, y+buf.Rect.Min.Y):]
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				dx, dy := rs.rotatePoint(x, y, buf.Rect.Size())
+				i := rs.buf.PixOffset(dx, dy)
+				copy(rs.buf.Pix[i:i+4], row[4*(x-rect.Min.X):])
+			}
+		}
+	}
+}
+
+// rotatePoint returns the location of pixel (x, y) of a frame of the given size after rotation.
+func (rs *rotatedSink) rotatePoint(x, y int, size image.Point) (int, int) {
+	switch rs.quarter {
+	case 1:
+		return size.Y - 1 - y, x
+	case 2:
+		return size.X - 1 - x, size.Y - 1 - y
+	default:
+		return y, size.X - 1 - x
+	}
+}
+
+// rotateRect returns the area covered by rect of a frame of the given size after rotation.
+func (rs *rotatedSink) rotateRect(rect image.Rectangle, size image.Point) image.Rectangle {
+	switch rs.quarter {
+	case 1:
+		return image.Rect(size.Y-rect.Max.Y, rect.Min.X, size.Y-rect.Min.Y, rect.Max.X)
+	case 2:
+		return image.Rect(size.X-rect.Max.X, size.Y-rect.Max.Y, size.X-rect.Min.X, size.Y-rect.Min.Y)
+	default:
+		return image.Rect(rect.Min.Y, size.X-rect.Max.X, rect.Max.Y, size.X-rect.Min.X)
+	}
+}
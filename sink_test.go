@@ -0,0 +1,162 @@
+package touch_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+// recordingSink records the pixels and rects flushed to it.
+type recordingSink struct {
+	mu      sync.Mutex
+	flushed chan []image.Rectangle
+	last    *image.RGBA
+	err     error
+}
+
+func newRecordingSink(err error) *recordingSink {
+	return &recordingSink{flushed: make(chan []image.Rectangle, 16), err: err}
+}
+
+func (s *recordingSink) Flush(buf *image.RGBA, rects []image.Rectangle) error {
+	s.mu.Lock()
+	s.last = image.NewRGBA(buf.Rect)
+	copy(s.last.Pix, buf.Pix)
+	s.mu.Unlock()
+	s.flushed <- append([]image.Rectangle(nil), rects...)
+	return s.err
+}
+
+func (s *recordingSink) wait(t *testing.T) []image.Rectangle {
+	t.Helper()
+	select {
+	case rects := <-s.flushed:
+		return rects
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for a flush")
+		return nil
+	}
+}
+
+// timedSink records the frame time of each flush.
+type timedSink struct {
+	*recordingSink
+	times chan time.Time
+}
+
+func (s *timedSink) FlushAt(buf *image.RGBA, rects []image.Rectangle, frameTime time.Time) error {
+	s.times <- frameTime
+	return s.Flush(buf, rects)
+}
+
+func TestSinks(t *testing.T) {
+	red := color.RGBA{0xFF, 0, 0, 0xFF}
+
+	t.Run("Failing Sink Is Removed", func(t *testing.T) {
+		runloop := startHeadless(t, nil)
+		good, bad := newRecordingSink(nil), newRecordingSink(errors.New("unplugged"))
+		failures := make(chan error, 1)
+		layer := &touch.BasicLayer{Background: red}
+		runloop.Do(func() error {
+			window := runloop.Window
+			window.OnSinkError = func(sink touch.DisplaySink, err error) {
+				if sink == bad {
+					failures <- err
+				}
+			}
+			window.AddSink(bad)
+			window.AddSink(good)
+			layer.Self = layer
+			layer.SetFrame(image.Rect(0, 0, 4, 4))
+			window.AddChild(layer)
+			return nil
+		})
+		good.wait(t)
+		bad.wait(t)
+		if err := <-failures; err.Error() != "unplugged" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		runloop.Do(func() error {
+			if sinks := runloop.Window.Sinks(); len(sinks) != 1 || sinks[0] != good {
+				t.Errorf("Expected only the good sink to remain: %v", sinks)
+			}
+			layer.SetFrame(image.Rect(2, 2, 6, 6))
+			return nil
+		})
+		if rects := good.wait(t); len(rects) == 0 {
+			t.Error("Expected the remaining sink to receive the next frame")
+		}
+	})
+	t.Run("Timed Sink Receives Frame Time", func(t *testing.T) {
+		clock := touch.NewManualClock(time.Unix(100, 0))
+		runloop := startHeadless(t, clock)
+		sink := &timedSink{recordingSink: newRecordingSink(nil), times: make(chan time.Time, 16)}
+		runloop.Do(func() error {
+			runloop.Window.AddSink(sink)
+			runloop.Window.Invalidate()
+			return nil
+		})
+		sink.wait(t)
+		if frameTime := <-sink.times; !frameTime.Equal(time.Unix(100, 0)) {
+			t.Errorf("Expected the runloop's frame time, got %v", frameTime)
+		}
+	})
+	t.Run("Failure Without RunLoop Is Reported", func(t *testing.T) {
+		display := &touch.Display{}
+		display.InitHeadless(8, 8)
+		window := &touch.Window{}
+		window.Init(display)
+		var reported error
+		window.OnSinkError = func(sink touch.DisplaySink, err error) {
+			reported = err
+		}
+		window.SinkFailed(newRecordingSink(nil), errors.New("failed"))
+		if reported == nil {
+			t.Error("Expected the failure to be reported")
+		}
+	})
+	t.Run("Redraw Without RunLoop", func(t *testing.T) {
+		display := &touch.Display{}
+		display.InitHeadless(8, 8)
+		window := &touch.Window{}
+		window.Init(display)
+		sink := newRecordingSink(nil)
+		window.AddSink(sink)
+
+		flushed := 0
+		window.Redraw(func(buf *image.RGBA) {
+			flushed += buf.Rect.Dx() * buf.Rect.Dy()
+		})
+		if flushed != 64 {
+			t.Errorf("Expected the whole window to be flushed, got %d pixels", flushed)
+		}
+		if rects := sink.wait(t); len(rects) != 1 || rects[0] != window.Rectangle {
+			t.Errorf("Expected the sink to receive the whole window: %v", rects)
+		}
+	})
+	t.Run("Rotated Sink", func(t *testing.T) {
+		inner := newRecordingSink(nil)
+		sink, err := touch.RotateSink(inner, 90)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := image.NewRGBA(image.Rect(0, 0, 6, 4))
+		buf.Set(1, 0, red)
+		sink.Flush(buf, []image.Rectangle{image.Rect(1, 0, 3, 1)})
+
+		if rects := inner.wait(t); len(rects) != 1 || rects[0] != image.Rect(3, 1, 4, 3) {
+			t.Errorf("Unexpected rotated rects: %v", rects)
+		}
+		if inner.last.Rect != image.Rect(0, 0, 4, 6) || inner.last.RGBAAt(3, 1) != red {
+			t.Errorf("Expected a 4x6 frame with the pixel at (3,1): %v", inner.last.Rect)
+		}
+		if _, err := touch.RotateSink(inner, 45); err == nil {
+			t.Error("Expected an error for a rotation of 45 degrees")
+		}
+	})
+}
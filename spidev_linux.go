@@ -0,0 +1,135 @@
+package touch
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+	"unsafe"
+)
+
+const (
+	spiIocWrMode        = 0x40016b01 // SPI_IOC_WR_MODE
+	spiIocWrMaxSpeedHz  = 0x40046b04 // SPI_IOC_WR_MAX_SPEED_HZ
+	spidevMaxTransfer   = 4096       // Default bufsiz of the spidev driver
+	sysfsGPIO           = "/sys/class/gpio"
+	sysfsExportAttempts = 20
+)
+
+// SPIDev is an SPITransport for a Linux spidev device, with the panel's
+// data/command line on a GPIO pin exported through sysfs.
+type SPIDev struct {
+	dev *os.File
+	dc  *os.File
+	// Current level of the DC line
+	data bool
+}
+
+// OpenSPIDev opens an spidev device, such as /dev/spidev0.0, in SPI mode 0 at
+// speedHz, using GPIO dcPin as the data/command line.
+func OpenSPIDev(device string, speedHz uint32, dcPin int) (*SPIDev, error) {
+	dev, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	mode := uint8(0)
+	if err := ioctl(dev.Fd(), spiIocWrMode, unsafe.Pointer(&mode)); err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("%s: setting SPI mode: %w", device, err)
+	}
+	if err := ioctl(dev.Fd(), spiIocWrMaxSpeedHz, unsafe.Pointer(&speedHz)); err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("%s: setting SPI speed: %w", device, err)
+	}
+
+	dc, err := openGPIOOutput(dcPin)
+	if err != nil {
+		dev.Close()
+		return nil, err
+	}
+	s := &SPIDev{dev: dev, dc: dc, data: true}
+	// Start from a known DC level
+	if err := s.setData(false); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// openGPIOOutput exports pin through sysfs and configures it as an output.
+func openGPIOOutput(pin int) (*os.File, error) {
+	name := strconv.Itoa(pin)
+	pinDir := sysfsGPIO + "/gpio" + name
+	if _, err := os.Stat(pinDir); os.IsNotExist(err) {
+		if err := os.WriteFile(sysfsGPIO+"/export", []byte(name), 0); err != nil {
+			return nil, fmt.Errorf("exporting GPIO %d: %w", pin, err)
+		}
+	}
+	// udev may take a moment to make a newly exported pin writable
+	var err error
+	for attempt := 0; attempt < sysfsExportAttempts; attempt++ {
+		if err = os.WriteFile(pinDir+"/direction", []byte("out"), 0); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("configuring GPIO %d: %w", pin, err)
+	}
+	return os.OpenFile(pinDir+"/value", os.O_WRONLY, 0)
+}
+
+func (s *SPIDev) setData(data bool) error {
+	if data == s.data {
+		return nil
+	}
+	level := []byte("0")
+	if data {
+		level[0] = '1'
+	}
+	if _, err := s.dc.WriteAt(level, 0); err != nil {
+		return err
+	}
+	s.data = data
+	return nil
+}
+
+func (s *SPIDev) write(b []byte) error {
+	for len(b) > 0 {
+		n := len(b)
+		if n > spidevMaxTransfer {
+			n = spidevMaxTransfer
+		}
+		if _, err := s.dev.Write(b[:n]); err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func (s *SPIDev) Command(cmd byte, params ...byte) error {
+	if err := s.setData(false); err != nil {
+		return err
+	}
+	if err := s.write([]byte{cmd}); err != nil {
+		return err
+	}
+	if len(params) == 0 {
+		return nil
+	}
+	return s.Data(params)
+}
+
+func (s *SPIDev) Data(data []byte) error {
+	if err := s.setData(true); err != nil {
+		return err
+	}
+	return s.write(data)
+}
+
+// Close closes the spidev device and GPIO. The pin remains exported.
+func (s *SPIDev) Close() error {
+	s.dc.Close()
+	return s.dev.Close()
+}
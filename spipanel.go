@@ -0,0 +1,195 @@
+package touch
+
+import (
+	"fmt"
+	"image"
+	"time"
+)
+
+// SPITransport sends commands and data to a panel controller. Controllers
+// distinguish the two with a data/command (DC) line alongside the SPI bus.
+type SPITransport interface {
+	// Command sends cmd with DC low, followed by any parameters with DC high.
+	Command(cmd byte, params ...byte) error
+	// Data sends data with DC high.
+	Data(data []byte) error
+}
+
+// PanelController identifies the controller chip of an SPI panel.
+type PanelController int
+
+const (
+	ILI9341 PanelController = iota
+	ST7789
+)
+
+// MIPI DCS commands shared by ILI9341 and ST7789 controllers
+const (
+	dcsSoftReset      = 0x01
+	dcsExitSleep      = 0x11
+	dcsInvertOn       = 0x21
+	dcsDisplayOn      = 0x29
+	dcsColumnAddress  = 0x2A // CASET
+	dcsRowAddress     = 0x2B // RASET
+	dcsMemoryWrite    = 0x2C // RAMWR
+	dcsAddressMode    = 0x36 // MADCTL
+	dcsPixelFormat    = 0x3A // COLMOD
+	dcsPixelFormat565 = 0x55
+)
+
+// MADCTL values for rotations of 0, 90, 180 and 270 degrees.
+// ILI9341 panels are usually wired for BGR (0x08) order.
+var panelAddressModes = map[PanelController][4]byte{
+	ILI9341: {0x48, 0x28, 0x88, 0xE8},
+	ST7789:  {0x00, 0x60, 0xC0, 0xA0},
+}
+
+// MADCTL bits that mirror rows and columns, and exchange them
+const (
+	madctlMY = 0x80
+	madctlMX = 0x40
+	madctlMV = 0x20
+)
+
+// Memory of both controllers is 240 columns by 320 rows
+var panelMemorySize = image.Point{240, 320}
+
+// panelInitStep is a command sent to initialize a panel, and the time to wait afterward.
+type panelInitStep struct {
+	cmd    byte
+	params []byte
+	delay  time.Duration
+}
+
+// SPIPanel drives an ILI9341 or ST7789 panel directly over SPI, for boards
+// without an fbtft framebuffer driver. In place of a framebuffer, the window
+// draws to a headless display of the panel's Size, and the panel receives its
+// frames with AddSink; only the regions that change are sent over SPI.
+//
+//	transport, err := touch.OpenSPIDev("/dev/spidev0.0", 32000000, 25)
+//	...
+//	panel := &touch.SPIPanel{}
+//	err = panel.Init(transport, touch.ST7789, 240, 320, 0)
+//	display.InitHeadless(panel.Size.X, panel.Size.Y)
+//	window.Init(display)
+//	window.AddSink(panel)
+type SPIPanel struct {
+	Transport  SPITransport
+	Controller PanelController
+	// Size in pixels, after rotation
+	Size image.Point
+	// Offset of the visible area in the controller's memory, for panels
+	// smaller than the controller's full resolution (e.g. 240x240 ST7789).
+	// It is given for a rotation of 0, and moved to match the panel's rotation.
+	Offset image.Point
+
+	mode byte // MADCTL value for the rotation
+	buf  []byte
+}
+
+// Init resets and configures the controller for 16-bit color and the given
+// rotation, and turns on the display. Width and height are the panel's natural
+// dimensions; they are swapped in Size for rotations of 90 and 270 degrees.
+func (p *SPIPanel) Init(transport SPITransport, controller PanelController, w, h, rotation int) error {
+	modes, ok := panelAddressModes[controller]
+	if !ok {
+		return fmt.Errorf("unknown panel controller %d", controller)
+	}
+	quarter := (rotation / 90) & 3
+	if rotation%90 != 0 {
+		return fmt.Errorf("unsupported panel rotation %d", rotation)
+	}
+	if quarter&1 != 0 {
+		w, h = h, w
+	}
+	*p = SPIPanel{
+		Transport:  transport,
+		Controller: controller,
+		Size:       image.Point{w, h},
+		Offset:     p.Offset,
+		mode:       modes[quarter],
+	}
+
+	steps := []panelInitStep{
+		{dcsSoftReset, nil, 150 * time.Millisecond},
+		{dcsExitSleep, nil, 120 * time.Millisecond},
+		{dcsPixelFormat, []byte{dcsPixelFormat565}, 0},
+		{dcsAddressMode, []byte{modes[quarter]}, 0},
+	}
+	if controller == ST7789 {
+		// ST7789 panels are built to be driven with inverted colors
+		steps = append(steps, panelInitStep{dcsInvertOn, nil, 0})
+	}
+	for _, step := range steps {
+		if err := transport.Command(step.cmd, step.params...); err != nil {
+			return err
+		}
+		time.Sleep(step.delay)
+	}
+	return transport.Command(dcsDisplayOn)
+}
+
+// addressOffset returns Offset, moved from rotation 0 to the address space of the panel's rotation.
+// MX and MY mirror the columns and rows of the controller's memory, then MV exchanges them.
+func (p *SPIPanel) addressOffset() image.Point {
+	// The panel's natural size
+	w, h := p.Size.X, p.Size.Y
+	if p.mode&madctlMV != 0 {
+		w, h = h, w
+	}
+	mem := panelMemorySize
+	// mirror moves the start of an area along an axis to the other end of memory, if mirrored is set
+	mirror := func(start, size, memSize int, mirrored bool) int {
+		if mirrored {
+			return memSize - size - start
+		}
+		return start
+	}
+
+	// Undo the mirroring of rotation 0, then apply the rotation's
+	base := panelAddressModes[p.Controller][0]
+	col := mirror(p.Offset.X, w, mem.X, base&madctlMX != 0)
+	row := mirror(p.Offset.Y, h, mem.Y, base&madctlMY != 0)
+	col = mirror(col, w, mem.X, p.mode&madctlMX != 0)
+	row = mirror(row, h, mem.Y, p.mode&madctlMY != 0)
+	if p.mode&madctlMV != 0 {
+		return image.Pt(row, col)
+	}
+	return image.Pt(col, row)
+}
+
+// Flush sends the pixels in each of rects to the panel, in big-endian RGB565.
+func (p *SPIPanel) Flush(buf *image.RGBA, rects []image.Rectangle) error {
+	for _, rect := range rects {
+		rect = rect.Intersect(image.Rectangle{Max: p.Size})
+		if rect.Empty() {
+			continue
+		}
+		// Address ranges are inclusive
+		offset := p.addressOffset()
+		x0, x1 := rect.Min.X+offset.X, rect.Max.X-1+offset.X
+		y0, y1 := rect.Min.Y+offset.Y, rect.Max.Y-1+offset.Y
+		if err := p.Transport.Command(dcsColumnAddress, byte(x0>>8), byte(x0), byte(x1>>8), byte(x1)); err != nil {
+			return err
+		}
+		if err := p.Transport.Command(dcsRowAddress, byte(y0>>8), byte(y0), byte(y1>>8), byte(y1)); err != nil {
+			return err
+		}
+		if err := p.Transport.Command(dcsMemoryWrite); err != nil {
+			return err
+		}
+
+		p.buf = p.buf[:0]
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			row := buf.Pix[buf.PixOffset(rect.Min.X, y):buf.PixOffset(rect.Max.X, y)]
+			for i := 0; i < len(row); i += 4 {
+				lo, hi := pixel565(row[i], row[i+1], row[i+2])
+				p.buf = append(p.buf, hi, lo)
+			}
+		}
+		if err := p.Transport.Data(p.buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
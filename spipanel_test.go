@@ -0,0 +1,110 @@
+package touch_test
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+// recordingTransport records the commands and data sent to a panel.
+type recordingTransport struct {
+	log []string
+}
+
+func (r *recordingTransport) Command(cmd byte, params ...byte) error {
+	r.log = append(r.log, fmt.Sprintf("cmd %02X % X", cmd, params))
+	return nil
+}
+
+func (r *recordingTransport) Data(data []byte) error {
+	r.log = append(r.log, fmt.Sprintf("data % X", data))
+	return nil
+}
+
+func TestSPIPanel(t *testing.T) {
+	transport := &recordingTransport{}
+	panel := &touch.SPIPanel{}
+	if err := panel.Init(transport, touch.ST7789, 240, 320, 90); err != nil {
+		t.Fatal(err)
+	}
+	if panel.Size != image.Pt(320, 240) {
+		t.Errorf("Rotated panel should be 320x240: %v", panel.Size)
+	}
+
+	t.Run("Init Sequence", func(t *testing.T) {
+		expected := []string{
+			"cmd 01 ", // Reset
+			"cmd 11 ", // Exit sleep
+			"cmd 3A 55",
+			"cmd 36 60", // Rotated 90 degrees
+			"cmd 21 ",
+			"cmd 29 ",
+		}
+		if !reflect.DeepEqual(transport.log, expected) {
+			t.Errorf("Unexpected init sequence:\n%q\nExpected:\n%q", transport.log, expected)
+		}
+	})
+	t.Run("Flush Sends Dirty Rects", func(t *testing.T) {
+		transport.log = nil
+
+		buf := image.NewRGBA(image.Rect(0, 0, 320, 240))
+		buf.Set(260, 2, color.RGBA{0xFF, 0, 0, 0xFF})
+		buf.Set(261, 2, color.RGBA{0, 0, 0xFF, 0xFF})
+		buf.Set(260, 3, color.White)
+		panel.Flush(buf, []image.Rectangle{
+			image.Rect(260, 2, 262, 4),
+			image.Rect(318, 238, 330, 250), // Clipped to the panel
+		})
+
+		expected := []string{
+			"cmd 2A 01 04 01 05",
+			"cmd 2B 00 02 00 03",
+			"cmd 2C ",
+			"data F8 00 00 1F FF FF 00 00",
+			"cmd 2A 01 3E 01 3F",
+			"cmd 2B 00 EE 00 EF",
+			"cmd 2C ",
+			"data 00 00 00 00 00 00 00 00",
+		}
+		if !reflect.DeepEqual(transport.log, expected) {
+			t.Errorf("Unexpected flush:\n%q\nExpected:\n%q", transport.log, expected)
+		}
+	})
+	t.Run("Offset Follows Rotation", func(t *testing.T) {
+		// A 240x240 panel showing the last 240 rows of the controller's memory
+		for _, test := range []struct {
+			controller touch.PanelController
+			rotation   int
+			// Start of the column and row addresses
+			col, row int
+		}{
+			{touch.ST7789, 0, 0, 80},
+			{touch.ST7789, 90, 80, 0},
+			{touch.ST7789, 180, 0, 0},
+			{touch.ST7789, 270, 0, 0},
+			{touch.ILI9341, 0, 0, 80},
+			{touch.ILI9341, 90, 80, 0},
+			{touch.ILI9341, 180, 0, 0},
+			{touch.ILI9341, 270, 0, 0},
+		} {
+			transport := &recordingTransport{}
+			panel := &touch.SPIPanel{Offset: image.Pt(0, 80)}
+			if err := panel.Init(transport, test.controller, 240, 240, test.rotation); err != nil {
+				t.Fatal(err)
+			}
+			transport.log = nil
+			panel.Flush(image.NewRGBA(image.Rect(0, 0, 240, 240)), []image.Rectangle{image.Rect(0, 0, 1, 1)})
+			expected := []string{
+				fmt.Sprintf("cmd 2A 00 %02X 00 %02X", test.col, test.col),
+				fmt.Sprintf("cmd 2B 00 %02X 00 %02X", test.row, test.row),
+			}
+			if !reflect.DeepEqual(transport.log[:2], expected) {
+				t.Errorf("Controller %d rotated %d: expected %q, got %q", test.controller, test.rotation, expected, transport.log[:2])
+			}
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package touch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+)
+
+// ErrRunLoopStopped is returned by Do and DoAsync for tasks that can't run,
+// because Run has returned.
+var ErrRunLoopStopped = errors.New("runloop stopped")
+
+// PanicError reports a panic recovered from a runloop task.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("runloop task panicked: %v", e.Value)
+}
+
+// Future is the eventual result of a task started with DoAsync.
+type Future struct {
+	done chan struct{}
+	err  error
+}
+
+// queuedTask is a task queued with Post, or by DoAsync for future.
+type queuedTask struct {
+	fn     func()
+	future *Future
+}
+
+// finish sets the result of the task, and releases any waiters.
+func (f *Future) finish(err error) {
+	f.err = err
+	close(f.done)
+}
+
+// Done returns a channel that is closed when the task has finished.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the task has finished, and returns its error.
+func (f *Future) Wait() error {
+	<-f.done
+	return f.err
+}
+
+// Post queues fn to run on the runloop. Unlike sending to Tasks,
+// Post never blocks. It is safe to call from any goroutine.
+// Tasks posted while the runloop is stopped run when it next runs.
+func (runloop *RunLoop) Post(fn func()) {
+	runloop.post(queuedTask{fn: fn})
+}
+
+func (runloop *RunLoop) post(task queuedTask) {
+	runloop.queueMu.Lock()
+	if runloop.stopped && task.future != nil {
+		runloop.queueMu.Unlock()
+		task.future.finish(ErrRunLoopStopped)
+		return
+	}
+	runloop.queue = append(runloop.queue, task)
+	runloop.queueMu.Unlock()
+
+	// Wake the runloop if it isn't already due to drain the queue
+	select {
+	case runloop.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// DoAsync runs fn on the runloop, and returns a Future for its result.
+// If fn panics, the Future's error is a *PanicError. If Run returns before fn
+// runs, or has already returned, fn is discarded and the error is ErrRunLoopStopped.
+func (runloop *RunLoop) DoAsync(fn func() error) *Future {
+	f := &Future{done: make(chan struct{})}
+	runloop.post(queuedTask{future: f, fn: func() {
+		var err error
+		defer func() {
+			if r := recover(); r != nil {
+				err = runloop.recovered(r)
+			}
+			f.finish(err)
+		}()
+		err = fn()
+	}})
+	return f
+}
+
+// Do runs fn on the runloop and waits for its result, so that other goroutines
+// may safely query or modify layers. Do must not be called from the runloop.
+// Before Run has started, Do waits for it; Once Run has returned, Do returns ErrRunLoopStopped.
+func (runloop *RunLoop) Do(fn func() error) error {
+	return runloop.DoAsync(fn).Wait()
+}
+
+// stop fails the queued tasks of Do and DoAsync, and any started before Run is called again.
+// Tasks queued with Post are kept for the next Run.
+func (runloop *RunLoop) stop() {
+	runloop.queueMu.Lock()
+	runloop.stopped = true
+	var failed []*Future
+	kept := runloop.queue[:0]
+	for _, task := range runloop.queue {
+		if task.future != nil {
+			failed = append(failed, task.future)
+		} else {
+			kept = append(kept, task)
+		}
+	}
+	runloop.queue = kept
+	runloop.queueMu.Unlock()
+
+	for _, f := range failed {
+		f.finish(ErrRunLoopStopped)
+	}
+}
+
+// drainQueue runs all tasks queued with Post.
+func (runloop *RunLoop) drainQueue() {
+	runloop.queueMu.Lock()
+	queue := runloop.queue
+	runloop.queue = nil
+	runloop.queueMu.Unlock()
+
+	for _, task := range queue {
+		runloop.runTask(task.fn)
+	}
+}
+
+// runTask runs a task, recovering from any panic so that the runloop survives.
+func (runloop *RunLoop) runTask(task func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			runloop.recovered(r)
+		}
+	}()
+	task()
+}
+
+// recovered reports a recovered panic value, and returns it as an error.
+func (runloop *RunLoop) recovered(r interface{}) error {
+	err := &PanicError{Value: r, Stack: debug.Stack()}
+	if handler := runloop.PanicHandler; handler != nil {
+		handler(err)
+	} else {
+		fmt.Fprintf(os.Stderr, "%v\n%s", err, err.Stack)
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+package touch_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+func TestTasks(t *testing.T) {
+	t.Run("Do Returns Error", func(t *testing.T) {
+		runloop := startHeadless(t, nil)
+		failure := errors.New("failed")
+		if err := runloop.Do(func() error { return failure }); err != failure {
+			t.Errorf("Expected the task's error, got %v", err)
+		}
+	})
+
+	panics := []struct {
+		name string
+		run  func(*touch.RunLoop, func() error) error
+	}{
+		{"Do", func(runloop *touch.RunLoop, fn func() error) error {
+			return runloop.Do(fn)
+		}},
+		{"DoAsync", func(runloop *touch.RunLoop, fn func() error) error {
+			f := runloop.DoAsync(fn)
+			select {
+			case <-f.Done():
+			case <-time.After(time.Second):
+				return errors.New("timed out")
+			}
+			return f.Wait()
+		}},
+	}
+	for _, test := range panics {
+		t.Run("Panic In "+test.name+" Is Recovered", func(t *testing.T) {
+			runloop := startHeadless(t, nil)
+			var handled []*touch.PanicError
+			runloop.Do(func() error {
+				runloop.PanicHandler = func(err *touch.PanicError) {
+					handled = append(handled, err)
+				}
+				return nil
+			})
+
+			err := test.run(runloop, func() error { panic("boom") })
+			var panicErr *touch.PanicError
+			if !errors.As(err, &panicErr) || panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+				t.Fatalf("Expected a PanicError with the value and stack, got %v", err)
+			}
+			// The runloop survives, and the handler has seen the same error
+			runloop.Do(func() error {
+				if len(handled) != 1 || handled[0] != panicErr {
+					t.Errorf("Expected the handler to receive the returned error once, got %v", handled)
+				}
+				return nil
+			})
+		})
+	}
+
+	t.Run("Do Fails After Run Returns", func(t *testing.T) {
+		runloop := newHeadless(nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			runloop.Run(ctx)
+			close(done)
+		}()
+		waitForTasks(runloop)
+		cancel()
+		<-done
+
+		ran := false
+		result := make(chan error, 1)
+		go func() {
+			result <- runloop.Do(func() error {
+				ran = true
+				return nil
+			})
+		}()
+		select {
+		case err := <-result:
+			if err != touch.ErrRunLoopStopped {
+				t.Errorf("Expected ErrRunLoopStopped, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Do blocked after Run returned")
+		}
+		if ran {
+			t.Error("Task ran after Run returned")
+		}
+
+		// Posted tasks wait for the next Run, which accepts tasks again
+		posted := make(chan struct{})
+		runloop.Post(func() { close(posted) })
+		ctx, cancel = context.WithCancel(context.Background())
+		done = make(chan struct{})
+		go func() {
+			runloop.Run(ctx)
+			close(done)
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
+		select {
+		case <-posted:
+		case <-time.After(time.Second):
+			t.Fatal("Posted task did not run when the runloop ran again")
+		}
+		if err := runloop.Do(func() error { return nil }); err != nil {
+			t.Errorf("Expected Do to succeed once running again, got %v", err)
+		}
+	})
+}
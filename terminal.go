@@ -0,0 +1,323 @@
+package touch
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// ErrTerminalClosed is returned by Flush once the Terminal has been closed.
+var ErrTerminalClosed = errors.New("terminal: closed")
+
+// Terminal draws a window in an ANSI terminal with 24-bit color, using
+// half-block characters so each character cell shows two rows of pixels.
+// The window is scaled down to fit the terminal, and redrawn when it is resized.
+// SGR mouse reports of left clicks and drags are delivered as touches, and
+// wheel reports as KeyPrevious and KeyNext. Terminals don't report releases,
+// so arrow, Tab, Enter, Space, Escape and Backspace keys are pressed and released at once.
+//
+// The terminal is the window's only output, so give the window a headless
+// display, and the terminal that display's size:
+//
+//	display.InitHeadless(320, 480)
+//	term, err := touch.OpenTerminal(&touch.MainRunLoop, display.Size)
+//	...
+//	defer term.Close()
+//	window.Init(display)
+//	window.AddSink(term)
+//
+// Anything else written to the terminal will be overdrawn; log to a file instead.
+type Terminal struct {
+	runloop *RunLoop
+	in, out *os.File
+	saved   syscall.Termios
+	resized chan os.Signal
+	closing sync.Once
+
+	// Guards output, and the mirror of the window's contents drawn when the terminal is resized
+	mu     sync.Mutex
+	closed bool
+	w      *bufio.Writer
+	mirror *image.RGBA
+	// Window pixels per character cell horizontally; cells are twice as tall
+	scale      int
+	cols, rows int
+}
+
+// OpenTerminal switches the terminal on stdin and stdout to a raw, full-screen
+// mode that shows a window of the given size, and delivers its input to runloop.
+func OpenTerminal(runloop *RunLoop, size image.Point) (*Terminal, error) {
+	t := &Terminal{
+		runloop: runloop,
+		in:      os.Stdin,
+		out:     os.Stdout,
+		resized: make(chan os.Signal, 1),
+		mirror:  image.NewRGBA(image.Rectangle{Max: size}),
+	}
+	t.w = bufio.NewWriterSize(t.out, 1<<16)
+
+	if err := ioctl(t.in.Fd(), ioctlGetTermios, unsafe.Pointer(&t.saved)); err != nil {
+		return nil, fmt.Errorf("terminal: %w", err)
+	}
+	raw := t.saved
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	// ISIG stays set, so Ctrl-C still interrupts the program
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.IEXTEN
+	raw.Cc[syscall.VMIN], raw.Cc[syscall.VTIME] = 1, 0
+	if err := ioctl(t.in.Fd(), ioctlSetTermios, unsafe.Pointer(&raw)); err != nil {
+		return nil, fmt.Errorf("terminal: %w", err)
+	}
+
+	// Alternate screen, hidden cursor, and SGR mouse reports for presses, releases and drags
+	t.w.WriteString("\x1b[?1049h\x1b[?25l\x1b[?1002h\x1b[?1006h")
+	t.resize()
+
+	signal.Notify(t.resized, syscall.SIGWINCH)
+	go func() {
+		for range t.resized {
+			t.mu.Lock()
+			if !t.closed {
+				t.resize()
+			}
+			t.mu.Unlock()
+		}
+	}()
+	go t.readLoop()
+	return t, nil
+}
+
+// Close restores the terminal to its original state. Only the first call has any effect.
+// Once closed, nothing more is drawn, and Flush returns ErrTerminalClosed.
+func (t *Terminal) Close() (err error) {
+	t.closing.Do(func() {
+		signal.Stop(t.resized)
+		close(t.resized)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.closed = true
+		t.w.WriteString("\x1b[?1006l\x1b[?1002l\x1b[0m\x1b[?25h\x1b[?1049l")
+		t.w.Flush()
+		err = ioctl(t.in.Fd(), ioctlSetTermios, unsafe.Pointer(&t.saved))
+	})
+	return
+}
+
+// Flush draws the character cells covering the updated rects of buf.
+func (t *Terminal) Flush(buf *image.RGBA, rects []image.Rectangle) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrTerminalClosed
+	}
+	for _, rect := range rects {
+		rect = rect.Intersect(t.mirror.Rect)
+		draw.Draw(t.mirror, rect, buf, rect.Min, draw.Src)
+		t.drawCells(rect)
+	}
+	return t.w.Flush()
+}
+
+// resize fits the window to the terminal's size, and redraws it.
+// The caller must hold t.mu, if other goroutines may be using t.
+func (t *Terminal) resize() {
+	cols, rows := 80, 24
+	var ws struct{ Row, Col, X, Y uint16 }
+	if ioctl(t.out.Fd(), syscall.TIOCGWINSZ, unsafe.Pointer(&ws)) == nil && ws.Col > 0 && ws.Row > 0 {
+		cols, rows = int(ws.Col), int(ws.Row)
+	}
+
+	size := t.mirror.Rect.Size()
+	t.scale = 1
+	for size.X > cols*t.scale || size.Y > 2*rows*t.scale {
+		t.scale++
+	}
+	t.cols = (size.X + t.scale - 1) / t.scale
+	t.rows = (size.Y + 2*t.scale - 1) / (2 * t.scale)
+
+	t.w.WriteString("\x1b[0m\x1b[2J")
+	t.drawCells(t.mirror.Rect)
+	t.w.Flush()
+}
+
+// drawCells draws the character cells covering rect.
+func (t *Terminal) drawCells(rect image.Rectangle) {
+	cellW, cellH := t.scale, 2*t.scale
+	col0, col1 := rect.Min.X/cellW, (rect.Max.X+cellW-1)/cellW
+	row0, row1 := rect.Min.Y/cellH, (rect.Max.Y+cellH-1)/cellH
+	if col1 > t.cols {
+		col1 = t.cols
+	}
+	if row1 > t.rows {
+		row1 = t.rows
+	}
+
+	var fg, bg color.RGBA
+	for row := row0; row < row1; row++ {
+		fmt.Fprintf(t.w, "\x1b[%d;%dH", row+1, col0+1)
+		for col := col0; col < col1; col++ {
+			// The upper half block is drawn in the foreground color, over the background
+			top := t.average(image.Rect(col*cellW, row*cellH, (col+1)*cellW, row*cellH+t.scale))
+			bottom := t.average(image.Rect(col*cellW, row*cellH+t.scale, (col+1)*cellW, (row+1)*cellH))
+			if col == col0 || top != fg {
+				fg = top
+				fmt.Fprintf(t.w, "\x1b[38;2;%d;%d;%dm", fg.R, fg.G, fg.B)
+			}
+			if col == col0 || bottom != bg {
+				bg = bottom
+				fmt.Fprintf(t.w, "\x1b[48;2;%d;%d;%dm", bg.R, bg.G, bg.B)
+			}
+			t.w.WriteString("▀")
+		}
+	}
+}
+
+// average returns the mean color of the mirror's pixels in rect, or black if there are none.
+func (t *Terminal) average(rect image.Rectangle) color.RGBA {
+	rect = rect.Intersect(t.mirror.Rect)
+	var r, g, b, n int
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		offset := t.mirror.PixOffset(rect.Min.X, y)
+		for px := t.mirror.Pix[offset : offset+4*rect.Dx()]; len(px) > 0; px = px[4:] {
+			r, g, b = r+int(px[0]), g+int(px[1]), b+int(px[2])
+			n++
+		}
+	}
+	if n == 0 {
+		return color.RGBA{A: 0xFF}
+	}
+	return color.RGBA{uint8(r / n), uint8(g / n), uint8(b / n), 0xFF}
+}
+
+// readLoop delivers mouse reports and keys typed in the terminal.
+func (t *Terminal) readLoop() {
+	buf := make([]byte, 256)
+	var pending []byte
+	for {
+		n, err := t.in.Read(buf)
+		if err != nil {
+			return
+		}
+		pending = t.parseInput(append(pending, buf[:n]...), n < len(buf))
+	}
+}
+
+// parseInput handles the complete key presses and mouse reports in input,
+// and returns any incomplete sequence left at its end. If idle is set, no
+// more input is waiting, so a lone Escape is a key press and not the start of a sequence.
+func (t *Terminal) parseInput(input []byte, idle bool) []byte {
+	for len(input) > 0 {
+		if input[0] != 0x1b {
+			switch input[0] {
+			case '\r', '\n':
+				t.pressKey(KeyEnter)
+			case '\t':
+				t.pressKey(KeyTab)
+			case ' ':
+				t.pressKey(KeySpace)
+			case 0x7f, 0x08:
+				t.pressKey(KeyBack)
+			}
+			input = input[1:]
+			continue
+		}
+
+		if len(input) == 1 || (len(input) == 2 && input[1] == '[') {
+			if !idle {
+				return input
+			}
+			// Nothing follows, so this is a lone Escape, or Alt+[
+			t.pressKey(KeyEscape)
+			return nil
+		}
+		if input[1] != '[' {
+			// Alt+key, or an Escape followed by another key
+			t.pressKey(KeyEscape)
+			input = input[1:]
+			continue
+		}
+
+		switch input[2] {
+		case 'A':
+			t.pressKey(KeyUp)
+		case 'B':
+			t.pressKey(KeyDown)
+		case 'C':
+			t.pressKey(KeyRight)
+		case 'D':
+			t.pressKey(KeyLeft)
+		case 'Z':
+			// Shift+Tab
+			t.pressKey(KeyPrevious)
+		case '<':
+			end := bytes.IndexAny(input, "Mm")
+			if end < 0 {
+				return input
+			}
+			t.handleMouse(input[3:end], input[end] == 'M')
+			input = input[end+1:]
+			continue
+		default:
+			// Skip other sequences, up to their final byte
+			end := 2
+			for end < len(input) && (input[end] < 0x40 || input[end] > 0x7e) {
+				end++
+			}
+			if end == len(input) {
+				return input
+			}
+			input = input[end+1:]
+			continue
+		}
+		input = input[3:]
+	}
+	return nil
+}
+
+// handleMouse handles an SGR mouse report, whose parameters are "button;col;row".
+func (t *Terminal) handleMouse(params []byte, pressed bool) {
+	fields := bytes.Split(params, []byte(";"))
+	if len(fields) != 3 {
+		return
+	}
+	button, _ := strconv.Atoi(string(fields[0]))
+	col, _ := strconv.Atoi(string(fields[1]))
+	row, _ := strconv.Atoi(string(fields[2]))
+
+	t.mu.Lock()
+	scale := t.scale
+	t.mu.Unlock()
+	// Touch the center of the cell
+	pt := image.Pt((col-1)*scale+scale/2, (row-1)*2*scale+scale)
+
+	const motion, wheel = 32, 64
+	switch {
+	case button&wheel != 0:
+		// Wheel up is button 64, and down 65
+		if button&1 == 0 {
+			t.runloop.InjectSteps(-1)
+		} else {
+			t.runloop.InjectSteps(1)
+		}
+	case button&^motion == 0:
+		// Left button
+		t.runloop.InjectTouch(TouchEvent{Point: pt, Pressed: pressed, Pressure: 0xFF})
+	}
+}
+
+// pressKey delivers a press and release, as terminals don't report key releases.
+func (t *Terminal) pressKey(key Key) {
+	t.runloop.InjectKey(KeyEvent{Key: key, Pressed: true})
+	t.runloop.InjectKey(KeyEvent{Key: key})
+}
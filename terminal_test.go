@@ -0,0 +1,151 @@
+package touch_test
+
+import (
+	"image"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+// keyLayer records the key events delivered to it while it has the focus.
+type keyLayer struct {
+	touchLayer
+	keys []touch.KeyEvent
+}
+
+func (l *keyLayer) CanFocus() bool  { return true }
+func (l *keyLayer) SetFocused(bool) {}
+
+func (l *keyLayer) HandleKey(e touch.KeyEvent) bool {
+	l.keys = append(l.keys, e)
+	return true
+}
+
+func TestTerminalInput(t *testing.T) {
+	runloop := startHeadless(t, nil)
+	layer := &keyLayer{}
+	runloop.Do(func() error {
+		layer.Self = layer
+		layer.SetFrame(image.Rect(0, 0, 32, 32))
+		runloop.Window.AddChild(layer)
+		runloop.Window.SetFocus(layer)
+		return nil
+	})
+	term := touch.NewTestTerminal(runloop, 1)
+
+	press := func(keys ...touch.Key) (events []touch.KeyEvent) {
+		for _, key := range keys {
+			events = append(events, touch.KeyEvent{Key: key, Pressed: true}, touch.KeyEvent{Key: key})
+		}
+		return
+	}
+	tests := []struct {
+		name    string
+		input   []string
+		idle    bool
+		keys    []touch.KeyEvent
+		touches []touch.TouchEvent
+		pending string
+	}{
+		{name: "Arrows", input: []string{"\x1b[A\x1b[B\x1b[C\x1b[D"},
+			keys: press(touch.KeyUp, touch.KeyDown, touch.KeyRight, touch.KeyLeft)},
+		{name: "Plain Keys", input: []string{"\r\t \x7fx"},
+			keys: press(touch.KeyEnter, touch.KeyTab, touch.KeySpace, touch.KeyBack)},
+		{name: "Shift Tab", input: []string{"\x1b[Z"}, keys: press(touch.KeyPrevious)},
+		{name: "Other Sequences Are Skipped", input: []string{"\x1b[15~\x1b[1;5A\r"},
+			keys: press(touch.KeyEnter)},
+		{name: "Mouse Press And Release", input: []string{"\x1b[<0;3;2M\x1b[<0;3;2m"},
+			touches: []touch.TouchEvent{
+				{Point: image.Pt(2, 3), Pressed: true, Pressure: 0xFF},
+				{Point: image.Pt(2, 3), Pressure: 0xFF},
+			}},
+		{name: "Mouse Drag", input: []string{"\x1b[<0;1;1M\x1b[<32;5;1M\x1b[<0;5;1m"},
+			touches: []touch.TouchEvent{
+				{Point: image.Pt(0, 1), Pressed: true, Pressure: 0xFF},
+				{Point: image.Pt(4, 1), Pressed: true, Pressure: 0xFF},
+				{Point: image.Pt(4, 1), Pressure: 0xFF},
+			}},
+		{name: "Scroll Wheel", input: []string{"\x1b[<64;1;1M\x1b[<65;1;1M"},
+			keys: press(touch.KeyPrevious, touch.KeyNext)},
+		{name: "Other Buttons Are Ignored", input: []string{"\x1b[<2;1;1M\x1b[<2;1;1m"}},
+		{name: "Split Arrow", input: []string{"\x1b", "[", "A"}, keys: press(touch.KeyUp)},
+		{name: "Split Mouse Report", input: []string{"\x1b[<0;3", ";2M"},
+			touches: []touch.TouchEvent{{Point: image.Pt(2, 3), Pressed: true, Pressure: 0xFF}}},
+		{name: "Incomplete Sequence Is Pending", input: []string{"\x1b[<0;3"}, pending: "\x1b[<0;3"},
+		{name: "Lone Escape", input: []string{"\x1b"}, idle: true, keys: press(touch.KeyEscape)},
+		{name: "Lone Escape Bracket", input: []string{"\x1b["}, idle: true, keys: press(touch.KeyEscape)},
+		{name: "Escape Bracket Waits For More", input: []string{"\x1b["}, pending: "\x1b["},
+		{name: "Alt Key", input: []string{"\x1bx"}, idle: true, keys: press(touch.KeyEscape)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runloop.Do(func() error {
+				layer.keys, layer.events = nil, nil
+				return nil
+			})
+			var pending []byte
+			for _, input := range test.input {
+				pending = term.ParseInput(append(pending, input...), test.idle)
+			}
+			var keys []touch.KeyEvent
+			var touches []touch.TouchEvent
+			runloop.Do(func() error {
+				keys, touches = layer.keys, layer.events
+				return nil
+			})
+			for idx := range touches {
+				touches[idx].Cancel = nil
+			}
+			if string(pending) != test.pending {
+				t.Errorf("Expected %q pending, got %q", test.pending, pending)
+			}
+			if !reflect.DeepEqual(keys, test.keys) {
+				t.Errorf("Expected keys %v, got %v", test.keys, keys)
+			}
+			if !reflect.DeepEqual(touches, test.touches) {
+				t.Errorf("Expected touches %+v, got %+v", test.touches, touches)
+			}
+		})
+	}
+}
+
+func TestTerminalClose(t *testing.T) {
+	out, err := ioutil.TempFile(t.TempDir(), "terminal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	written := func() int64 {
+		info, err := out.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+	term := touch.NewTestTerminalOutput(out, image.Pt(8, 8))
+	buf := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	rects := []image.Rectangle{buf.Rect}
+
+	before := written()
+	if err := term.Flush(buf, rects); err != nil {
+		t.Fatalf("Flush failed while open: %v", err)
+	}
+	if written() == before {
+		t.Error("Expected Flush to draw while open")
+	}
+
+	// The output is not a terminal, so its settings can't be restored
+	term.Close()
+	closed := written()
+	if err := term.Flush(buf, rects); err != touch.ErrTerminalClosed {
+		t.Errorf("Expected ErrTerminalClosed, got %v", err)
+	}
+	if err := term.Close(); err != nil {
+		t.Errorf("Expected a second Close to do nothing, got %v", err)
+	}
+	if written() != closed {
+		t.Error("Expected nothing to be drawn after Close")
+	}
+}
@@ -0,0 +1,76 @@
+package touch
+
+import (
+	"sync"
+	"time"
+)
+
+// Timer is a function scheduled to run on a RunLoop by After or Every.
+type Timer struct {
+	runloop  *RunLoop
+	fn       func()
+	interval time.Duration
+
+	mu         sync.Mutex
+	clockTimer ClockTimer
+	done       bool
+}
+
+// After runs fn on the runloop once d has elapsed.
+func (runloop *RunLoop) After(d time.Duration, fn func()) *Timer {
+	t := &Timer{runloop: runloop, fn: fn}
+	t.schedule(d)
+	return t
+}
+
+// Every runs fn on the runloop each time interval elapses, until the timer is stopped.
+func (runloop *RunLoop) Every(interval time.Duration, fn func()) *Timer {
+	t := &Timer{runloop: runloop, fn: fn, interval: interval}
+	t.schedule(interval)
+	return t
+}
+
+// Stop cancels the timer. It is safe to call from any goroutine.
+// Returns true if this prevented the timer from running; For repeating
+// timers, returns true unless the timer was already stopped.
+func (t *Timer) Stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return false
+	}
+	t.done = true
+	t.clockTimer.Stop()
+	return true
+}
+
+func (t *Timer) schedule(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return
+	}
+	t.clockTimer = t.runloop.clock().AfterFunc(d, t.expire)
+}
+
+// expire is called by the clock, and hands the timer to the runloop.
+func (t *Timer) expire() {
+	if t.interval > 0 {
+		// Repeating timers are scheduled from the clock, so that they don't
+		// drift with the latency of the runloop.
+		t.schedule(t.interval)
+	}
+	t.runloop.Post(t.fire)
+}
+
+func (t *Timer) fire() {
+	t.mu.Lock()
+	if t.done {
+		t.mu.Unlock()
+		return
+	}
+	t.done = t.interval == 0
+	t.mu.Unlock()
+
+	t.fn()
+}
@@ -0,0 +1,93 @@
+package touch_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+// newHeadless prepares a runloop for a headless window, without running it.
+func newHeadless(clock touch.Clock) *touch.RunLoop {
+	display := &touch.Display{}
+	display.InitHeadless(32, 32)
+	window := &touch.Window{}
+	window.Init(display)
+
+	runloop := &touch.RunLoop{Clock: clock}
+	runloop.Init(window)
+	return runloop
+}
+
+// startHeadless runs a runloop for a headless window until the test ends.
+func startHeadless(t *testing.T, clock touch.Clock) *touch.RunLoop {
+	runloop := newHeadless(clock)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runloop.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return runloop
+}
+
+// waitForTasks waits for the runloop to finish all previously posted tasks.
+func waitForTasks(runloop *touch.RunLoop) {
+	runloop.Do(func() error { return nil })
+}
+
+func TestTimers(t *testing.T) {
+	clock := touch.NewManualClock(time.Unix(0, 0))
+	runloop := startHeadless(t, clock)
+
+	t.Run("After Runs Once", func(t *testing.T) {
+		count := 0
+		runloop.After(time.Second, func() { count++ })
+
+		clock.Advance(999 * time.Millisecond)
+		waitForTasks(runloop)
+		if count != 0 {
+			t.Errorf("Timer ran early: %d calls", count)
+		}
+		clock.Advance(5 * time.Second)
+		waitForTasks(runloop)
+		if count != 1 {
+			t.Errorf("Timer should run exactly once: %d calls", count)
+		}
+	})
+	t.Run("Every Repeats Until Stopped", func(t *testing.T) {
+		count := 0
+		timer := runloop.Every(time.Second, func() { count++ })
+
+		clock.Advance(3 * time.Second)
+		waitForTasks(runloop)
+		if count != 3 {
+			t.Errorf("Repeating timer should have run 3 times: %d calls", count)
+		}
+		if !timer.Stop() {
+			t.Error("Stopping a repeating timer should succeed")
+		}
+		clock.Advance(3 * time.Second)
+		waitForTasks(runloop)
+		if count != 3 {
+			t.Errorf("Stopped timer kept running: %d calls", count)
+		}
+	})
+	t.Run("Stop Prevents Pending Timer", func(t *testing.T) {
+		ran := false
+		timer := runloop.After(time.Second, func() { ran = true })
+		if !timer.Stop() {
+			t.Error("Stopping a pending timer should succeed")
+		}
+		clock.Advance(time.Minute)
+		waitForTasks(runloop)
+		if ran || timer.Stop() {
+			t.Error("Stopped timer should not run, or stop twice")
+		}
+	})
+}
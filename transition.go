@@ -0,0 +1,213 @@
+package touch
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"time"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+type TransitionStyle int
+
+const (
+	TransitionFade TransitionStyle = iota
+	TransitionSlide
+	TransitionScale
+)
+
+// Transition describes how a layer is animated when presented or dismissed.
+type Transition struct {
+	Style TransitionStyle
+	// Edge is the edge of the parent that TransitionSlide moves from or to.
+	Edge LayoutDirection
+	// Duration defaults to DefaultAnimationDuration
+	Duration time.Duration
+	// Curve defaults to EaseOut for presenting, and EaseIn for dismissing.
+	Curve Curve
+}
+
+// PresentChild adds child to parent and animates it in.
+// While animating, child is hidden beneath a snapshot drawn in its place, and touches are blocked.
+// Must be called from the runloop.
+func PresentChild(parent, child Layer, t Transition) *Animation {
+	parent.AddChild(child)
+	proxy := newTransitionLayer(child, t)
+	proxy.cover(parent, child)
+
+	curve := t.Curve
+	if curve == nil {
+		curve = EaseOut
+	}
+	return proxy.animate(parent, curve, 0, 1, func(bool) {
+		// Even if canceled, the child must end up presented
+		proxy.uncover(parent, child)
+	})
+}
+
+// DismissChild animates child out, and removes it from its parent once finished.
+// If the animation is canceled, child remains in its parent as it was.
+// Must be called from the runloop.
+func DismissChild(child Layer, t Transition) *Animation {
+	parent := child.Parent()
+	if parent == nil || childIndex(parent, child) < 0 {
+		// Nothing to dismiss; The layer was already removed.
+		return (&Animation{Update: func(float64) {}}).Start(nil)
+	}
+	proxy := newTransitionLayer(child, t)
+	proxy.cover(parent, child)
+
+	curve := t.Curve
+	if curve == nil {
+		curve = EaseIn
+	}
+	return proxy.animate(parent, curve, 1, 0, func(finished bool) {
+		proxy.uncover(parent, child)
+		if finished {
+			parent.RemoveChild(child)
+		}
+	})
+}
+
+// hideable is implemented by layers that can be hidden, such as BasicLayer.
+type hideable interface {
+	Hidden() bool
+	SetHidden(bool)
+}
+
+// cover hides child beneath the transition layer. Layers that can't be hidden
+// are removed from parent, and the transition layer is drawn in their place.
+func (tl *transitionLayer) cover(parent, child Layer) {
+	idx := childIndex(parent, child)
+	if h, ok := child.(hideable); ok {
+		tl.hid = !h.Hidden()
+		h.SetHidden(true)
+		parent.InsertChild(tl, idx+1)
+		return
+	}
+	parent.RemoveChild(child)
+	parent.InsertChild(tl, idx)
+}
+
+// uncover removes the transition layer, leaving child in its place as it was before cover.
+func (tl *transitionLayer) uncover(parent, child Layer) {
+	idx := childIndex(parent, tl)
+	parent.RemoveChild(tl)
+	if h, ok := child.(hideable); ok {
+		if tl.hid {
+			h.SetHidden(false)
+		}
+		return
+	}
+	parent.InsertChild(child, idx)
+}
+
+// childIndex returns the position of child among the children of parent, or -1.
+func childIndex(parent, child Layer) int {
+	for idx, c := range parent.Children() {
+		if c == child {
+			return idx
+		}
+	}
+	return -1
+}
+
+// transitionLayer draws a snapshot of a layer being presented or dismissed.
+type transitionLayer struct {
+	BasicLayer
+	Transition
+	snapshot *image.RGBA
+	// Visible fraction of the layer, from 0 (hidden) to 1 (presented)
+	progress float64
+	// Whether cover hid the layer, which uncover shows again
+	hid bool
+}
+
+func newTransitionLayer(layer Layer, t Transition) *transitionLayer {
+	buffer := &Buffer{RGBA: image.NewRGBA(layer.Frame())}
+	layer.Render(buffer)
+
+	tl := &transitionLayer{Transition: t, snapshot: buffer.RGBA}
+	tl.Self = tl
+	tl.SetFrame(layer.Frame())
+	return tl
+}
+
+func (tl *transitionLayer) animate(parent Layer, curve Curve, from, to float64, end func(bool)) *Animation {
+	duration := tl.Duration
+	if duration == 0 {
+		duration = DefaultAnimationDuration
+	}
+	bounds := parent.Frame()
+	a := &Animation{
+		Duration: duration,
+		Curve:    curve,
+		Update: func(p float64) {
+			tl.progress = from + (to-from)*p
+			tl.SetFrame(tl.frameAt(bounds))
+			tl.Invalidate()
+		},
+		end: end,
+	}
+	a.Update(0)
+	return a.Start(windowOf(parent).RunLoop())
+}
+
+// frameAt returns the rect the snapshot is drawn in at the current progress.
+func (tl *transitionLayer) frameAt(bounds image.Rectangle) image.Rectangle {
+	rect := tl.snapshot.Rect
+	switch tl.Style {
+	case TransitionSlide:
+		var offset image.Point
+		switch tl.Edge {
+		case FromLeft:
+			offset.X = bounds.Min.X - rect.Max.X
+		case FromRight:
+			offset.X = bounds.Max.X - rect.Min.X
+		case FromTop:
+			offset.Y = bounds.Min.Y - rect.Max.Y
+		case FromBottom:
+			offset.Y = bounds.Max.Y - rect.Min.Y
+		}
+		return rect.Add(lerpPoint(offset, image.Point{}, tl.progress))
+	case TransitionScale:
+		// Grow from 80% size about the center
+		inset := lerpPoint(rect.Size().Div(10), image.Point{}, tl.progress)
+		return image.Rectangle{Min: rect.Min.Add(inset), Max: rect.Max.Sub(inset)}
+	}
+	return rect
+}
+
+func (tl *transitionLayer) DrawIn(ctx DrawingContext) {
+	src := tl.snapshot
+	switch tl.Style {
+	case TransitionSlide:
+		draw.Draw(ctx.Image(), tl.Rectangle, src, src.Rect.Min, draw.Over)
+	case TransitionFade, TransitionScale:
+		mask := image.NewUniform(color.Alpha{uint8(0xFF * clampUnit(tl.progress))})
+		if tl.Rectangle.Size() == src.Rect.Size() {
+			draw.DrawMask(ctx.Image(), tl.Rectangle, src, src.Rect.Min, mask, image.Point{}, draw.Over)
+		} else {
+			xdraw.ApproxBiLinear.Scale(ctx.Image(), tl.Rectangle, src, src.Rect, draw.Over, &xdraw.Options{DstMask: mask})
+		}
+	}
+	ctx.SetDirty(tl.Rectangle)
+}
+
+// HitTest blocks touches to the layer while it is animating.
+func (tl *transitionLayer) HitTest(event TouchEvent) LayerTouchDelegate {
+	if event.Pressed && event.In(tl.Rectangle) {
+		return touchBlocker{}
+	}
+	return nil
+}
+
+func clampUnit(v float64) float64 {
+	if v < 0 {
+		return 0
+	} else if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,165 @@
+package touch_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+func TestTransitions(t *testing.T) {
+	const frame = 100 * time.Millisecond
+	red := color.RGBA{0xFF, 0, 0, 0xFF}
+	childFrame := image.Rect(4, 4, 20, 20)
+	// The transitions take three frames, of 100ms each
+	fade := touch.Transition{Style: touch.TransitionFade, Duration: 300 * time.Millisecond}
+	slide := touch.Transition{Style: touch.TransitionSlide, Edge: touch.FromBottom, Duration: 300 * time.Millisecond}
+
+	newChild := func() *touch.BasicLayer {
+		child := &touch.BasicLayer{Background: red}
+		child.Self = child
+		child.SetFrame(childFrame)
+		return child
+	}
+	startWindow := func(t *testing.T) (*touch.RunLoop, *touch.ManualClock) {
+		clock := touch.NewManualClock(time.Unix(0, 0))
+		runloop := startHeadless(t, clock)
+		runloop.Do(func() error {
+			runloop.MaxFPS = 10
+			return nil
+		})
+		return runloop, clock
+	}
+	advance := func(runloop *touch.RunLoop, clock *touch.ManualClock, frames int) {
+		for ; frames > 0; frames-- {
+			clock.Advance(frame)
+			waitForTasks(runloop)
+		}
+	}
+	// contains reports whether layer is a child of parent.
+	contains := func(parent, layer touch.Layer) bool {
+		for _, child := range parent.Children() {
+			if child == layer {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("Present Adds Child At Once", func(t *testing.T) {
+		runloop, clock := startWindow(t)
+		child := newChild()
+		var completions []bool
+		runloop.Do(func() error {
+			a := touch.PresentChild(runloop.Window, child, fade)
+			a.Completion = func(finished bool) { completions = append(completions, finished) }
+			if !contains(runloop.Window, child) || child.Parent() != runloop.Window {
+				t.Error("Expected the child to be added to its parent at once")
+			}
+			if !child.Hidden() || len(runloop.Window.Children()) != 2 {
+				t.Error("Expected the child to be hidden beneath its snapshot while presenting")
+			}
+			return nil
+		})
+		advance(runloop, clock, 2)
+		runloop.Do(func() error {
+			if len(completions) != 0 || !child.Hidden() {
+				t.Error("Expected the presentation to continue for three frames")
+			}
+			return nil
+		})
+		advance(runloop, clock, 1)
+		runloop.Do(func() error {
+			if len(completions) != 1 || !completions[0] {
+				t.Errorf("Expected the presentation to finish, got completions %v", completions)
+			}
+			if children := runloop.Window.Children(); len(children) != 1 || children[0] != child || child.Hidden() {
+				t.Errorf("Expected only the shown child to remain, got %d children", len(children))
+			}
+			return nil
+		})
+		if c := pixelAt(runloop, 10, 10); c != red {
+			t.Errorf("Expected the presented child to be drawn, got %v", c)
+		}
+	})
+	t.Run("Dismiss Removes Child When Finished", func(t *testing.T) {
+		runloop, clock := startWindow(t)
+		child := newChild()
+		var completions []bool
+		runloop.Do(func() error {
+			runloop.Window.AddChild(child)
+			a := touch.DismissChild(child, fade)
+			a.Completion = func(finished bool) { completions = append(completions, finished) }
+			return nil
+		})
+		advance(runloop, clock, 2)
+		runloop.Do(func() error {
+			if !contains(runloop.Window, child) {
+				t.Error("Expected the child to remain until the animation finishes")
+			}
+			return nil
+		})
+		advance(runloop, clock, 1)
+		runloop.Do(func() error {
+			if len(completions) != 1 || !completions[0] {
+				t.Errorf("Expected the dismissal to finish, got completions %v", completions)
+			}
+			if children := runloop.Window.Children(); len(children) != 0 {
+				t.Errorf("Expected the child and its snapshot to be removed, got %d children", len(children))
+			}
+			return nil
+		})
+	})
+	t.Run("Canceled Dismiss Restores Child", func(t *testing.T) {
+		runloop, clock := startWindow(t)
+		child := newChild()
+		var a *touch.Animation
+		var opacity float64
+		runloop.Do(func() error {
+			child.SetOpacity(0.5)
+			opacity = child.Opacity()
+			runloop.Window.AddChild(child)
+			a = touch.DismissChild(child, slide)
+			return nil
+		})
+		advance(runloop, clock, 1)
+		runloop.Do(func() error {
+			a.Cancel()
+			if children := runloop.Window.Children(); len(children) != 1 || children[0] != child {
+				t.Errorf("Expected only the child to remain, got %d children", len(children))
+			}
+			if child.Hidden() || child.Frame() != childFrame || child.Opacity() != opacity {
+				t.Errorf("Expected the child as it was, got frame %v and opacity %v", child.Frame(), child.Opacity())
+			}
+			return nil
+		})
+		advance(runloop, clock, 3)
+		runloop.Do(func() error {
+			if !contains(runloop.Window, child) {
+				t.Error("Expected the child to remain after a canceled dismissal")
+			}
+			return nil
+		})
+	})
+	t.Run("Transitions Without RunLoop Are Immediate", func(t *testing.T) {
+		parent := &touch.BasicLayer{}
+		parent.Self = parent
+		parent.SetFrame(image.Rect(0, 0, 32, 32))
+		child := newChild()
+
+		if a := touch.PresentChild(parent, child, slide); a.IsRunning() {
+			t.Error("Expected the presentation to finish at once")
+		}
+		if children := parent.Children(); len(children) != 1 || children[0] != child || child.Hidden() || child.Frame() != childFrame {
+			t.Errorf("Expected the child to be presented, got %d children", len(children))
+		}
+		if a := touch.DismissChild(child, fade); a.IsRunning() {
+			t.Error("Expected the dismissal to finish at once")
+		}
+		if children := parent.Children(); len(children) != 0 {
+			t.Errorf("Expected the child to be removed, got %d children", len(children))
+		}
+	})
+}
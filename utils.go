@@ -1,5 +1,7 @@
 package touch
 
+import "image"
+
 // bytesFill optimally fills a byte array with a repeating pattern
 func bytesFill(b, pattern []byte) {
 	if l := copy(b, pattern); l > 0 {
@@ -9,3 +11,19 @@ func bytesFill(b, pattern []byte) {
 		}
 	}
 }
+
+// pixel565 packs a color into 16 bits, returning the low and high bytes.
+func pixel565(r, g, b byte) (byte, byte) {
+	return ((g & 0b00011100) << 3) | b>>3, (r & 0b11111000) | g>>5
+}
+
+// fadeRect scales the premultiplied pixels of img in rect by alpha.
+func fadeRect(img *image.RGBA, rect image.Rectangle, alpha uint8) {
+	rect = rect.Intersect(img.Rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(rect.Min.X, y):img.PixOffset(rect.Max.X, y)]
+		for i, v := range row {
+			row[i] = uint8(uint32(v) * uint32(alpha) / 0xFF)
+		}
+	}
+}
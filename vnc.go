@@ -0,0 +1,578 @@
+package touch
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"net"
+	"sync"
+)
+
+// DefaultVNCAddr is the address VNCServer listens on if Addr is empty.
+const DefaultVNCAddr = "localhost:5900"
+
+// VNCServer lets VNC (RFB 3.3 to 3.8) clients view and control a window.
+// Clients see the frames it receives as a window sink, so alongside a framebuffer
+// they watch the device's screen, and on a headless display nothing else does:
+//
+//	vnc := touch.NewVNCServer(&touch.MainRunLoop, display.Size)
+//	window.AddSink(vnc)
+//	...
+//	go vnc.ListenAndServe(ctx)
+//
+// Updates are sent with Zlib encoding to clients that support it, and Raw
+// otherwise. Each client receives only the regions that changed since its
+// last update, so slow clients skip intermediate frames instead of falling behind.
+// The left button of a client's pointer is delivered as touches, its wheel
+// buttons as KeyPrevious and KeyNext, and the keysyms of navigation keys as
+// the matching Keys; other keys are ignored.
+type VNCServer struct {
+	// Address to listen on; DefaultVNCAddr if empty.
+	Addr string
+	// Desktop name shown by clients
+	Name string
+	// If set, clients must authenticate with VNC Authentication. Only
+	// the first 8 characters are used, and traffic is not encrypted;
+	// use an SSH tunnel to reach a server that isn't on localhost.
+	Password string
+
+	runloop *RunLoop
+	// Guards the mirror of the window's contents, and the state of every client
+	mu      sync.Mutex
+	mirror  *image.RGBA
+	clients map[*vncClient]struct{}
+}
+
+type vncClient struct {
+	conn   net.Conn
+	wake   chan struct{}
+	format vncPixelFormat
+	zlib   bool
+	// Regions changed since the last update, and whether the client is waiting for one
+	dirty     RegionList
+	requested bool
+	// Owned by the write loop: the pixels being sent, copied from the mirror,
+	// and the compressor for the client's Zlib stream, which continues across updates
+	snapshot *image.RGBA
+	zbuf     bytes.Buffer
+	zw       *zlib.Writer
+
+	// Input state, owned by the read loop
+	buttons  byte
+	position image.Point
+	keysDown map[uint32]bool
+}
+
+type vncPixelFormat struct {
+	BitsPerPixel, Depth, BigEndian, TrueColor uint8
+	RedMax, GreenMax, BlueMax                 uint16
+	RedShift, GreenShift, BlueShift           uint8
+	_                                         [3]byte
+}
+
+// Pixels are offered in little-endian XRGB8888, but clients may choose any true color format.
+var vncDefaultFormat = vncPixelFormat{
+	BitsPerPixel: 32, Depth: 24, TrueColor: 1,
+	RedMax: 255, GreenMax: 255, BlueMax: 255,
+	RedShift: 16, GreenShift: 8, BlueShift: 0,
+}
+
+const (
+	vncSetPixelFormat           = 0
+	vncSetEncodings             = 2
+	vncFramebufferUpdateRequest = 3
+	vncKeyEvent                 = 4
+	vncPointerEvent             = 5
+	vncClientCutText            = 6
+
+	vncEncodingRaw  = 0
+	vncEncodingZlib = 6
+
+	vncSecurityNone = 1
+	vncSecurityVNC  = 2
+)
+
+// X keysyms sent by VNC clients, and the keys they are delivered as
+var vncKeys = map[uint32]Key{
+	0xff08: KeyBack,
+	0xff09: KeyTab,
+	0xff0d: KeyEnter,
+	0xff1b: KeyEscape,
+	0xff51: KeyLeft,
+	0xff52: KeyUp,
+	0xff53: KeyRight,
+	0xff54: KeyDown,
+	0xff55: KeyPrevious, // Page Up
+	0xff56: KeyNext,     // Page Down
+	0xff8d: KeyKPEnter,
+	0x0020: KeySpace,
+}
+
+// NewVNCServer returns a VNCServer for a window of the given size on runloop.
+func NewVNCServer(runloop *RunLoop, size image.Point) *VNCServer {
+	return &VNCServer{
+		Name:    "go-touch",
+		runloop: runloop,
+		mirror:  image.NewRGBA(image.Rectangle{Max: size}),
+		clients: map[*vncClient]struct{}{},
+	}
+}
+
+// Flush records the updated rects of buf, to be sent as each client requests them.
+func (s *VNCServer) Flush(buf *image.RGBA, rects []image.Rectangle) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rect := range rects {
+		rect = rect.Intersect(s.mirror.Rect)
+		draw.Draw(s.mirror, rect, buf, rect.Min, draw.Src)
+		for client := range s.clients {
+			client.dirty.AddRect(rect)
+		}
+	}
+	for client := range s.clients {
+		client.signal()
+	}
+	return nil
+}
+
+// ListenAndServe accepts VNC clients until ctx is done.
+func (s *VNCServer) ListenAndServe(ctx context.Context) error {
+	addr := s.Addr
+	if addr == "" {
+		addr = DefaultVNCAddr
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				select {
+				case <-ctx.Done():
+					conn.Close()
+				case <-done:
+				}
+			}()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+func (c *vncClient) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *VNCServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	if err := s.handshake(conn, r); err != nil {
+		return
+	}
+
+	client := &vncClient{
+		conn:     conn,
+		wake:     make(chan struct{}, 1),
+		format:   vncDefaultFormat,
+		keysDown: map[uint32]bool{},
+	}
+	// Fill the mirror and add the client in one runloop task, between frames, so that
+	// each later Flush marks its regions dirty for this client too.
+	err := s.runloop.Do(func() error {
+		buf := s.runloop.Window.RGBA
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		draw.Draw(s.mirror, s.mirror.Rect, buf, s.mirror.Rect.Min, draw.Src)
+		client.dirty.AddRect(s.mirror.Rect)
+		s.clients[client] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, client)
+		s.mu.Unlock()
+		client.release(s.runloop)
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.writeLoop(client, done)
+
+	for {
+		if err := s.readMessage(client, r); err != nil {
+			return
+		}
+	}
+}
+
+// handshake negotiates the protocol version and security, and sends ServerInit.
+func (s *VNCServer) handshake(conn net.Conn, r *bufio.Reader) error {
+	if _, err := io.WriteString(conn, "RFB 003.008\n"); err != nil {
+		return err
+	}
+	var version [12]byte
+	if _, err := io.ReadFull(r, version[:]); err != nil {
+		return err
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(string(version[:]), "RFB %03d.%03d\n", &major, &minor); err != nil || major != 3 {
+		return fmt.Errorf("vnc: unsupported version %q", version)
+	}
+
+	security := byte(vncSecurityNone)
+	if s.Password != "" {
+		security = vncSecurityVNC
+	}
+	if minor < 7 {
+		// The server chooses the security type
+		if err := binary.Write(conn, binary.BigEndian, uint32(security)); err != nil {
+			return err
+		}
+	} else {
+		if _, err := conn.Write([]byte{1, security}); err != nil {
+			return err
+		}
+		choice, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if choice != security {
+			return errors.New("vnc: unsupported security type")
+		}
+	}
+
+	ok := true
+	if security == vncSecurityVNC {
+		var err error
+		if ok, err = s.authenticate(conn, r); err != nil {
+			return err
+		}
+	}
+	// Versions before 3.8 only report the result of VNC Authentication
+	if security == vncSecurityVNC || minor >= 8 {
+		var msg bytes.Buffer
+		if ok {
+			binary.Write(&msg, binary.BigEndian, uint32(0))
+		} else {
+			binary.Write(&msg, binary.BigEndian, uint32(1))
+			if minor >= 8 {
+				reason := "authentication failed"
+				binary.Write(&msg, binary.BigEndian, uint32(len(reason)))
+				msg.WriteString(reason)
+			}
+		}
+		if _, err := conn.Write(msg.Bytes()); err != nil {
+			return err
+		}
+	}
+	if !ok {
+		return errors.New("vnc: authentication failed")
+	}
+
+	// ClientInit's shared flag is ignored; every client shares the window
+	if _, err := r.ReadByte(); err != nil {
+		return err
+	}
+	var init bytes.Buffer
+	size := s.mirror.Rect.Size()
+	binary.Write(&init, binary.BigEndian, uint16(size.X))
+	binary.Write(&init, binary.BigEndian, uint16(size.Y))
+	binary.Write(&init, binary.BigEndian, vncDefaultFormat)
+	binary.Write(&init, binary.BigEndian, uint32(len(s.Name)))
+	init.WriteString(s.Name)
+	_, err := conn.Write(init.Bytes())
+	return err
+}
+
+// authenticate performs VNC Authentication: the client must DES-encrypt a random challenge with the password.
+func (s *VNCServer) authenticate(conn net.Conn, r *bufio.Reader) (bool, error) {
+	challenge := make([]byte, 16)
+	if _, err := rand.Read(challenge); err != nil {
+		return false, err
+	}
+	if _, err := conn.Write(challenge); err != nil {
+		return false, err
+	}
+	response := make([]byte, 16)
+	if _, err := io.ReadFull(r, response); err != nil {
+		return false, err
+	}
+
+	// The key is the password, truncated or zero-padded to 8 bytes, with the bits of each byte reversed.
+	key := make([]byte, 8)
+	copy(key, s.Password)
+	for i, b := range key {
+		b = (b&0xF0)>>4 | (b&0x0F)<<4
+		b = (b&0xCC)>>2 | (b&0x33)<<2
+		key[i] = (b&0xAA)>>1 | (b&0x55)<<1
+	}
+	cipher, err := des.NewCipher(key)
+	if err != nil {
+		return false, err
+	}
+	expected := make([]byte, 16)
+	cipher.Encrypt(expected[:8], challenge[:8])
+	cipher.Encrypt(expected[8:], challenge[8:])
+	return subtle.ConstantTimeCompare(expected, response) == 1, nil
+}
+
+// readMessage handles one message from the client.
+func (s *VNCServer) readMessage(client *vncClient, r *bufio.Reader) error {
+	msgType, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	switch msgType {
+	case vncSetPixelFormat:
+		var msg struct {
+			_      [3]byte
+			Format vncPixelFormat
+		}
+		if err := binary.Read(r, binary.BigEndian, &msg); err != nil {
+			return err
+		}
+		f := msg.Format
+		if f.TrueColor == 0 || (f.BitsPerPixel != 8 && f.BitsPerPixel != 16 && f.BitsPerPixel != 32) {
+			return errors.New("vnc: unsupported pixel format")
+		}
+		s.mu.Lock()
+		client.format = f
+		s.mu.Unlock()
+
+	case vncSetEncodings:
+		var msg struct {
+			_     byte
+			Count uint16
+		}
+		if err := binary.Read(r, binary.BigEndian, &msg); err != nil {
+			return err
+		}
+		encodings := make([]int32, msg.Count)
+		if err := binary.Read(r, binary.BigEndian, encodings); err != nil {
+			return err
+		}
+		useZlib := false
+		for _, encoding := range encodings {
+			if encoding == vncEncodingZlib {
+				useZlib = true
+			}
+		}
+		s.mu.Lock()
+		client.zlib = useZlib
+		s.mu.Unlock()
+
+	case vncFramebufferUpdateRequest:
+		var msg struct {
+			Incremental uint8
+			X, Y, W, H  uint16
+		}
+		if err := binary.Read(r, binary.BigEndian, &msg); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		client.requested = true
+		if msg.Incremental == 0 {
+			rect := image.Rect(int(msg.X), int(msg.Y), int(msg.X)+int(msg.W), int(msg.Y)+int(msg.H))
+			client.dirty.AddRect(rect.Intersect(s.mirror.Rect))
+		}
+		s.mu.Unlock()
+		client.signal()
+
+	case vncKeyEvent:
+		var msg struct {
+			Down   uint8
+			_      [2]byte
+			Keysym uint32
+		}
+		if err := binary.Read(r, binary.BigEndian, &msg); err != nil {
+			return err
+		}
+		if key, ok := vncKeys[msg.Keysym]; ok {
+			pressed := msg.Down != 0
+			repeat := pressed && client.keysDown[msg.Keysym]
+			client.keysDown[msg.Keysym] = pressed
+			s.runloop.InjectKey(KeyEvent{Key: key, Pressed: pressed, Repeat: repeat})
+		}
+
+	case vncPointerEvent:
+		var msg struct {
+			Buttons uint8
+			X, Y    uint16
+		}
+		if err := binary.Read(r, binary.BigEndian, &msg); err != nil {
+			return err
+		}
+		client.pointer(s.runloop, msg.Buttons, image.Pt(int(msg.X), int(msg.Y)))
+
+	case vncClientCutText:
+		var msg struct {
+			_      [3]byte
+			Length uint32
+		}
+		if err := binary.Read(r, binary.BigEndian, &msg); err != nil {
+			return err
+		}
+		if _, err := r.Discard(int(msg.Length)); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("vnc: unknown message type %d", msgType)
+	}
+	return nil
+}
+
+func (c *vncClient) pointer(runloop *RunLoop, buttons uint8, pt image.Point) {
+	const left, wheelUp, wheelDown = 1 << 0, 1 << 3, 1 << 4
+	pressed := buttons&left != 0
+	if pressed || c.buttons&left != 0 {
+		// Deliver presses, drags and releases of the left button
+		runloop.InjectTouch(TouchEvent{Point: pt, Pressed: pressed, Pressure: 0xFF})
+	}
+	// Clients send a press and release of the wheel "buttons" for each step
+	if buttons&wheelUp != 0 && c.buttons&wheelUp == 0 {
+		runloop.InjectSteps(-1)
+	}
+	if buttons&wheelDown != 0 && c.buttons&wheelDown == 0 {
+		runloop.InjectSteps(1)
+	}
+	c.buttons, c.position = buttons, pt
+}
+
+// release ends any touch or key press left active by a client that disconnected.
+func (c *vncClient) release(runloop *RunLoop) {
+	if c.buttons&1 != 0 {
+		runloop.InjectTouch(TouchEvent{Point: c.position, Pressure: 0xFF})
+	}
+	for keysym, down := range c.keysDown {
+		if down {
+			runloop.InjectKey(KeyEvent{Key: vncKeys[keysym]})
+		}
+	}
+}
+
+// writeLoop sends an update whenever the client has requested one and regions have changed.
+func (s *VNCServer) writeLoop(client *vncClient, done <-chan struct{}) {
+	for {
+		select {
+		case <-client.wake:
+		case <-done:
+			return
+		}
+
+		// Copy the changed regions, so that encoding doesn't delay Flush or other clients
+		s.mu.Lock()
+		var rects []image.Rectangle
+		if client.requested && len(client.dirty.Rects) > 0 {
+			client.requested = false
+			rects = append(rects, client.dirty.Dequeue()...)
+			if client.snapshot == nil || client.snapshot.Rect != s.mirror.Rect {
+				client.snapshot = image.NewRGBA(s.mirror.Rect)
+			}
+			for _, rect := range rects {
+				copyRect(client.snapshot, s.mirror, rect)
+			}
+		}
+		format, useZlib := client.format, client.zlib
+		s.mu.Unlock()
+
+		if len(rects) > 0 {
+			msg := client.encodeUpdate(format, useZlib, rects)
+			if _, err := client.conn.Write(msg); err != nil {
+				client.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// encodeUpdate returns a FramebufferUpdate message for rects of the client's snapshot.
+// Only the write loop may call it.
+func (c *vncClient) encodeUpdate(format vncPixelFormat, useZlib bool, rects []image.Rectangle) []byte {
+	var msg bytes.Buffer
+	msg.Write([]byte{0, 0})
+	binary.Write(&msg, binary.BigEndian, uint16(len(rects)))
+	for _, rect := range rects {
+		header := struct {
+			X, Y, W, H uint16
+			Encoding   int32
+		}{uint16(rect.Min.X), uint16(rect.Min.Y), uint16(rect.Dx()), uint16(rect.Dy()), vncEncodingRaw}
+		if useZlib {
+			header.Encoding = vncEncodingZlib
+		}
+		binary.Write(&msg, binary.BigEndian, header)
+
+		pixels := format.encode(nil, c.snapshot, rect)
+		if header.Encoding == vncEncodingZlib {
+			if c.zw == nil {
+				c.zw = zlib.NewWriter(&c.zbuf)
+			}
+			c.zbuf.Reset()
+			c.zw.Write(pixels)
+			c.zw.Flush()
+			binary.Write(&msg, binary.BigEndian, uint32(c.zbuf.Len()))
+			msg.Write(c.zbuf.Bytes())
+		} else {
+			msg.Write(pixels)
+		}
+	}
+	return msg.Bytes()
+}
+
+// encode appends the pixels of img in rect to dst, in format f.
+func (f *vncPixelFormat) encode(dst []byte, img *image.RGBA, rect image.Rectangle) []byte {
+	bytesPerPixel := int(f.BitsPerPixel / 8)
+	var order binary.ByteOrder = binary.LittleEndian
+	if f.BigEndian != 0 {
+		order = binary.BigEndian
+	}
+	var px [4]byte
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		offset := img.PixOffset(rect.Min.X, y)
+		for src := img.Pix[offset : offset+4*rect.Dx()]; len(src) > 0; src = src[4:] {
+			v := uint32(src[0])*uint32(f.RedMax)/255<<f.RedShift |
+				uint32(src[1])*uint32(f.GreenMax)/255<<f.GreenShift |
+				uint32(src[2])*uint32(f.BlueMax)/255<<f.BlueShift
+			switch bytesPerPixel {
+			case 1:
+				px[0] = byte(v)
+			case 2:
+				order.PutUint16(px[:], uint16(v))
+			default:
+				order.PutUint32(px[:], v)
+			}
+			dst = append(dst, px[:bytesPerPixel]...)
+		}
+	}
+	return dst
+}
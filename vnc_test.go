@@ -0,0 +1,200 @@
+package touch_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/des"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+// vncConnect starts serving a client over a pipe, and returns the client's end.
+func vncConnect(t *testing.T, server *touch.VNCServer) net.Conn {
+	client, conn := net.Pipe()
+	go server.ServeConn(conn)
+	t.Cleanup(func() { client.Close() })
+	client.SetDeadline(time.Now().Add(2 * time.Second))
+	return client
+}
+
+func vncRead(t *testing.T, r io.Reader, n int) []byte {
+	t.Helper()
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func vncWrite(t *testing.T, w io.Writer, data ...interface{}) {
+	t.Helper()
+	for _, v := range data {
+		if err := binary.Write(w, binary.BigEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// vncReadServerInit reads ServerInit, and checks the framebuffer size and name.
+func vncReadServerInit(t *testing.T, conn net.Conn) {
+	t.Helper()
+	init := vncRead(t, conn, 24)
+	if w, h := binary.BigEndian.Uint16(init), binary.BigEndian.Uint16(init[2:]); w != 32 || h != 32 {
+		t.Errorf("Expected a 32x32 framebuffer, got %dx%d", w, h)
+	}
+	if bpp := init[4]; bpp != 32 {
+		t.Errorf("Expected 32 bits per pixel, got %d", bpp)
+	}
+	if name := vncRead(t, conn, int(binary.BigEndian.Uint32(init[20:]))); string(name) != "go-touch" {
+		t.Errorf("Unexpected desktop name %q", name)
+	}
+}
+
+// vncHandshake completes an RFB 3.8 handshake without authentication.
+func vncHandshake(t *testing.T, conn net.Conn) {
+	t.Helper()
+	if version := vncRead(t, conn, 12); string(version) != "RFB 003.008\n" {
+		t.Fatalf("Unexpected version %q", version)
+	}
+	io.WriteString(conn, "RFB 003.008\n")
+	if types := vncRead(t, conn, 2); !bytes.Equal(types, []byte{1, 1}) {
+		t.Fatalf("Expected only security type None, got %v", types)
+	}
+	vncWrite(t, conn, uint8(1))
+	if result := vncRead(t, conn, 4); !bytes.Equal(result, []byte{0, 0, 0, 0}) {
+		t.Fatalf("Expected SecurityResult OK, got %v", result)
+	}
+	vncWrite(t, conn, uint8(1))
+	vncReadServerInit(t, conn)
+}
+
+func TestVNCServer(t *testing.T) {
+	red := color.RGBA{0xFF, 0, 0, 0xFF}
+	runloop := startHeadless(t, nil)
+	runloop.Do(func() error {
+		runloop.Window.Background = red
+		runloop.Window.Invalidate()
+		return nil
+	})
+	pixelAt(runloop, 0, 0)
+	server := touch.NewVNCServer(runloop, image.Pt(32, 32))
+
+	t.Run("Handshake 3.8", func(t *testing.T) {
+		vncHandshake(t, vncConnect(t, server))
+	})
+	t.Run("Handshake 3.3", func(t *testing.T) {
+		conn := vncConnect(t, server)
+		vncRead(t, conn, 12)
+		io.WriteString(conn, "RFB 003.003\n")
+		// The server chooses the security type, and sends no result for None
+		if security := vncRead(t, conn, 4); !bytes.Equal(security, []byte{0, 0, 0, 1}) {
+			t.Fatalf("Expected security type None, got %v", security)
+		}
+		vncWrite(t, conn, uint8(1))
+		vncReadServerInit(t, conn)
+	})
+	t.Run("VNC Authentication", func(t *testing.T) {
+		server := touch.NewVNCServer(runloop, image.Pt(32, 32))
+		server.Password = "secret"
+		respond := func(conn net.Conn, password string) []byte {
+			vncRead(t, conn, 12)
+			io.WriteString(conn, "RFB 003.008\n")
+			if types := vncRead(t, conn, 2); !bytes.Equal(types, []byte{1, 2}) {
+				t.Fatalf("Expected only VNC Authentication, got %v", types)
+			}
+			vncWrite(t, conn, uint8(2))
+			challenge := vncRead(t, conn, 16)
+			// DES keys are the password with the bits of each byte reversed
+			key := make([]byte, 8)
+			copy(key, password)
+			for i, b := range key {
+				var r byte
+				for bit := 0; bit < 8; bit++ {
+					r |= (b >> bit & 1) << (7 - bit)
+				}
+				key[i] = r
+			}
+			cipher, _ := des.NewCipher(key)
+			response := make([]byte, 16)
+			cipher.Encrypt(response[:8], challenge[:8])
+			cipher.Encrypt(response[8:], challenge[8:])
+			conn.Write(response)
+			return vncRead(t, conn, 4)
+		}
+
+		conn := vncConnect(t, server)
+		if result := respond(conn, "secret"); !bytes.Equal(result, []byte{0, 0, 0, 0}) {
+			t.Fatalf("Expected the password to be accepted, got %v", result)
+		}
+		vncWrite(t, conn, uint8(1))
+		vncReadServerInit(t, conn)
+
+		conn = vncConnect(t, server)
+		if result := respond(conn, "wrong"); !bytes.Equal(result, []byte{0, 0, 0, 1}) {
+			t.Fatalf("Expected the password to be rejected, got %v", result)
+		}
+		if reason := vncRead(t, conn, int(binary.BigEndian.Uint32(vncRead(t, conn, 4)))); len(reason) == 0 {
+			t.Error("Expected a reason for the failure")
+		}
+	})
+
+	// requestUpdate sets the client's encodings, requests the whole framebuffer,
+	// and returns the encoding and data of the single rect in the update.
+	requestUpdate := func(t *testing.T, conn net.Conn, encoding int32) (int32, io.Reader) {
+		vncWrite(t, conn, uint8(2), uint8(0), uint16(1), encoding)
+		vncWrite(t, conn, uint8(3), uint8(0), uint16(0), uint16(0), uint16(32), uint16(32))
+		header := vncRead(t, conn, 4)
+		if header[0] != 0 || binary.BigEndian.Uint16(header[2:]) != 1 {
+			t.Fatalf("Expected an update of one rect, got %v", header)
+		}
+		var rect struct {
+			X, Y, W, H uint16
+			Encoding   int32
+		}
+		binary.Read(conn, binary.BigEndian, &rect)
+		if rect.X != 0 || rect.Y != 0 || rect.W != 32 || rect.H != 32 {
+			t.Fatalf("Expected the whole framebuffer, got %+v", rect)
+		}
+		return rect.Encoding, conn
+	}
+	// checkPixels reads the framebuffer as little-endian XRGB8888, and checks that it is red.
+	checkPixels := func(t *testing.T, r io.Reader) {
+		pixels := vncRead(t, r, 32*32*4)
+		for i := 0; i < len(pixels); i += 4 {
+			if px := binary.LittleEndian.Uint32(pixels[i:]); px != 0xFF0000 {
+				t.Fatalf("Expected red at pixel %d, got %06X", i/4, px)
+			}
+		}
+	}
+
+	t.Run("Raw Encoding", func(t *testing.T) {
+		conn := vncConnect(t, server)
+		vncHandshake(t, conn)
+		encoding, r := requestUpdate(t, conn, 0)
+		if encoding != 0 {
+			t.Fatalf("Expected Raw encoding, got %d", encoding)
+		}
+		checkPixels(t, r)
+	})
+	t.Run("Zlib Encoding", func(t *testing.T) {
+		conn := vncConnect(t, server)
+		vncHandshake(t, conn)
+		encoding, r := requestUpdate(t, conn, 6)
+		if encoding != 6 {
+			t.Fatalf("Expected Zlib encoding, got %d", encoding)
+		}
+		length := binary.BigEndian.Uint32(vncRead(t, r, 4))
+		zr, err := zlib.NewReader(bytes.NewReader(vncRead(t, r, int(length))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPixels(t, zr)
+	})
+}
@@ -0,0 +1,230 @@
+package touch
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// A minimal server-side WebSocket (RFC 6455), sufficient for the simulator.
+// Extensions and subprotocols are not supported.
+
+const (
+	wsText   = 0x1
+	wsBinary = 0x2
+	wsClose  = 0x8
+	wsPing   = 0x9
+	wsPong   = 0xA
+
+	// Largest message accepted from a client
+	wsMaxMessage = 1 << 16
+	// Largest payload of a control frame
+	wsMaxControl = 125
+
+	// Close status codes
+	wsStatusProtocolError = 1002
+	wsStatusTooLarge      = 1009
+)
+
+var errWSMessageTooLarge = errors.New("websocket: message too large")
+
+// wsProtocolError reports a client that broke the protocol; the connection is closed with status 1002.
+type wsProtocolError string
+
+func (e wsProtocolError) Error() string {
+	return "websocket: " + string(e)
+}
+
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	wmu  sync.Mutex
+}
+
+// upgradeWebSocket completes the opening handshake for r, taking over its connection.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "expected a WebSocket handshake", http.StatusBadRequest)
+		return nil, errors.New("websocket: not a handshake request")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection can't be upgraded", http.StatusInternalServerError)
+		return nil, errors.New("websocket: response does not implement http.Hijacker")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next text or binary message, answering pings as they arrive.
+// It returns io.EOF once the client closes the connection. If the client breaks the
+// protocol, or sends a message that is too large, the connection is closed with a
+// status code explaining why.
+func (c *wsConn) ReadMessage() (opcode byte, msg []byte, err error) {
+	opcode, msg, err = c.readMessage()
+	var protocolErr wsProtocolError
+	if errors.As(err, &protocolErr) {
+		c.writeClose(wsStatusProtocolError)
+	} else if err == errWSMessageTooLarge {
+		c.writeClose(wsStatusTooLarge)
+	}
+	return
+}
+
+func (c *wsConn) readMessage() (opcode byte, msg []byte, err error) {
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case wsPing:
+			if err := c.WriteMessage(wsPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.WriteMessage(wsClose, nil)
+			return 0, nil, io.EOF
+		case 0:
+			// Continuation of a fragmented message
+			if opcode == 0 {
+				return 0, nil, wsProtocolError("continuation without a message")
+			}
+		default:
+			if opcode != 0 {
+				return 0, nil, wsProtocolError("message interrupts a fragmented message")
+			}
+			opcode = op
+		}
+		if len(msg)+len(payload) > wsMaxMessage {
+			return 0, nil, errWSMessageTooLarge
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return opcode, msg, nil
+		}
+	}
+}
+
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.rw, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.rw, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.rw, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if !masked {
+		err = wsProtocolError("client frames must be masked")
+		return
+	}
+	if opcode&0x8 != 0 && (!fin || length > wsMaxControl) {
+		err = wsProtocolError("control frames must be unfragmented, with at most 125 bytes")
+		return
+	}
+	if length > wsMaxMessage {
+		err = errWSMessageTooLarge
+		return
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.rw, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.rw, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// WriteMessage sends msg as a single unmasked frame. It is safe to call concurrently.
+func (c *wsConn) WriteMessage(opcode byte, msg []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	var header [10]byte
+	header[0] = 0x80 | opcode
+	size := 2
+	switch n := len(msg); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+		size += 2
+	default:
+		header[1] = 127
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+		size += 8
+	}
+	c.rw.Write(header[:size])
+	c.rw.Write(msg)
+	return c.rw.Flush()
+}
+
+// writeClose sends a close frame with a status code.
+func (c *wsConn) writeClose(status uint16) error {
+	var payload [2]byte
+	binary.BigEndian.PutUint16(payload[:], status)
+	return c.WriteMessage(wsClose, payload[:])
+}
+
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,228 @@
+package touch_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+// wsClient is the client end of a WebSocket connected to an echo server.
+type wsClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	// The error that ended the server's read loop
+	serverErr chan error
+}
+
+func dialWebSocket(t *testing.T, key string) (*wsClient, *http.Response) {
+	serverErr := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := touch.UpgradeWebSocket(w, r)
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer ws.Close()
+		for {
+			opcode, msg, err := ws.ReadMessage()
+			if err != nil {
+				serverErr <- err
+				return
+			}
+			ws.WriteMessage(opcode, msg)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n"+
+		"Upgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: "+key+"\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &wsClient{conn: conn, r: r, serverErr: serverErr}, resp
+}
+
+// send writes a frame, masked unless mask is nil.
+func (c *wsClient) send(fin bool, opcode byte, payload []byte, mask []byte) {
+	var frame bytes.Buffer
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame.WriteByte(first)
+	maskBit := byte(0)
+	if mask != nil {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame.WriteByte(maskBit | byte(n))
+	default:
+		frame.WriteByte(maskBit | 126)
+		binary.Write(&frame, binary.BigEndian, uint16(n))
+	}
+	if mask != nil {
+		frame.Write(mask)
+		for i, b := range payload {
+			frame.WriteByte(b ^ mask[i%4])
+		}
+	} else {
+		frame.Write(payload)
+	}
+	c.conn.Write(frame.Bytes())
+}
+
+// receive reads an unmasked frame from the server.
+func (c *wsClient) receive(t *testing.T) (opcode byte, payload []byte) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	if header[0]&0x80 == 0 || header[1]&0x80 != 0 {
+		t.Fatalf("Expected a final, unmasked frame, got header %v", header)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		io.ReadFull(c.r, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+// expectClose reads a close frame, and checks its status code.
+func (c *wsClient) expectClose(t *testing.T, status uint16) {
+	t.Helper()
+	opcode, payload := c.receive(t)
+	if opcode != 0x8 || len(payload) != 2 || binary.BigEndian.Uint16(payload) != status {
+		t.Fatalf("Expected close with status %d, got opcode %d payload %v", status, opcode, payload)
+	}
+	select {
+	case err := <-c.serverErr:
+		if err == nil {
+			t.Error("Expected the server to fail reading")
+		}
+	case <-time.After(time.Second):
+		t.Error("Timed out waiting for the server to stop reading")
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	mask := []byte{0x37, 0xfa, 0x21, 0x3d}
+
+	t.Run("Handshake", func(t *testing.T) {
+		// The example from RFC 6455, section 1.3
+		_, resp := dialWebSocket(t, "dGhlIHNhbXBsZSBub25jZQ==")
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("Expected 101 Switching Protocols, got %v", resp.Status)
+		}
+		if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+			t.Errorf("Unexpected Sec-WebSocket-Accept %q", accept)
+		}
+		if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+			t.Errorf("Unexpected Upgrade %q", resp.Header.Get("Upgrade"))
+		}
+	})
+	t.Run("Not A Handshake", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			touch.UpgradeWebSocket(w, r)
+		}))
+		defer server.Close()
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 Bad Request, got %v", resp.Status)
+		}
+	})
+	t.Run("Masked Message", func(t *testing.T) {
+		client, _ := dialWebSocket(t, "dGhlIHNhbXBsZSBub25jZQ==")
+		// The single-frame masked text message from RFC 6455, section 5.7
+		client.conn.Write([]byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58})
+		if opcode, msg := client.receive(t); opcode != 0x1 || string(msg) != "Hello" {
+			t.Errorf("Expected Hello echoed as text, got opcode %d %q", opcode, msg)
+		}
+	})
+	t.Run("Extended Length", func(t *testing.T) {
+		client, _ := dialWebSocket(t, "dGhlIHNhbXBsZSBub25jZQ==")
+		msg := bytes.Repeat([]byte{0xA5}, 300)
+		client.send(true, 0x2, msg, mask)
+		if opcode, echoed := client.receive(t); opcode != 0x2 || !bytes.Equal(echoed, msg) {
+			t.Errorf("Expected 300 bytes echoed as binary, got opcode %d, %d bytes", opcode, len(echoed))
+		}
+	})
+	t.Run("Fragmented Message With Ping", func(t *testing.T) {
+		client, _ := dialWebSocket(t, "dGhlIHNhbXBsZSBub25jZQ==")
+		client.send(false, 0x1, []byte("Hel"), mask)
+		client.send(true, 0x9, []byte("ping"), mask)
+		client.send(true, 0x0, []byte("lo"), mask)
+		if opcode, payload := client.receive(t); opcode != 0xA || string(payload) != "ping" {
+			t.Errorf("Expected a pong with the ping's payload, got opcode %d %q", opcode, payload)
+		}
+		if opcode, msg := client.receive(t); opcode != 0x1 || string(msg) != "Hello" {
+			t.Errorf("Expected the fragments joined, got opcode %d %q", opcode, msg)
+		}
+	})
+	t.Run("Close", func(t *testing.T) {
+		client, _ := dialWebSocket(t, "dGhlIHNhbXBsZSBub25jZQ==")
+		client.send(true, 0x8, nil, mask)
+		if opcode, _ := client.receive(t); opcode != 0x8 {
+			t.Errorf("Expected the close to be answered, got opcode %d", opcode)
+		}
+		if err := <-client.serverErr; err != io.EOF {
+			t.Errorf("Expected io.EOF, got %v", err)
+		}
+	})
+	t.Run("Message Too Large", func(t *testing.T) {
+		client, _ := dialWebSocket(t, "dGhlIHNhbXBsZSBub25jZQ==")
+		header := []byte{0x82, 0x80 | 127, 0, 0, 0, 0, 0, 2, 0, 0}
+		client.conn.Write(header)
+		client.expectClose(t, 1009)
+	})
+
+	protocolErrors := []struct {
+		name string
+		send func(*wsClient)
+	}{
+		{"Unmasked Frame", func(c *wsClient) { c.send(true, 0x1, []byte("Hello"), nil) }},
+		{"Long Control Frame", func(c *wsClient) { c.send(true, 0x9, make([]byte, 126), mask) }},
+		{"Fragmented Control Frame", func(c *wsClient) { c.send(false, 0x9, []byte("ping"), mask) }},
+		{"Unexpected Continuation", func(c *wsClient) { c.send(true, 0x0, []byte("lo"), mask) }},
+		{"Interrupted Fragments", func(c *wsClient) {
+			c.send(false, 0x1, []byte("Hel"), mask)
+			c.send(true, 0x1, []byte("lo"), mask)
+		}},
+	}
+	for _, test := range protocolErrors {
+		t.Run(test.name, func(t *testing.T) {
+			client, _ := dialWebSocket(t, "dGhlIHNhbXBsZSBub25jZQ==")
+			test.send(client)
+			client.expectClose(t, 1002)
+		})
+	}
+}
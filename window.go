@@ -1,7 +1,6 @@
 package touch
 
 import (
-	"fmt"
 	"image"
 	"image/color"
 	"time"
@@ -9,8 +8,22 @@ import (
 
 type Window struct {
 	BufferedLayer
+	// ShowCursor enables drawing a cursor at the position of the mouse, if any.
+	ShowCursor bool
+	// OnFrame, if set, receives statistics for each frame flushed to the display.
+	OnFrame func(FrameStats)
+	// OnSinkError, if set, is called on the runloop when a sink fails and is removed.
+	// By default, the error is printed to stderr.
+	OnSinkError func(DisplaySink, error)
+
 	display  *Display
+	runloop  *RunLoop
 	redrawCh chan struct{}
+	focused  Focusable
+	overlays []Layer
+	cursor   *CursorLayer
+	debug    *DebugOverlay
+	sinks    []*sinkWorker
 }
 
 func (w *Window) Init(display *Display) {
@@ -20,13 +33,65 @@ func (w *Window) Init(display *Display) {
 	w.display = display
 }
 
+// RunLoop returns the runloop driving this window, or nil.
+// Nil windows are callable, for layers that are not in a window.
+func (w *Window) RunLoop() *RunLoop {
+	if w == nil {
+		return nil
+	}
+	return w.runloop
+}
+
 func (w *Window) Calibrate(ev *TouchEvent) {
 	w.display.Calibration.Adjust(ev)
 }
 
+// AddOverlay adds a layer that is drawn above all children, and never receives touches.
+func (w *Window) AddOverlay(layer Layer) {
+	w.overlays = append(w.overlays, layer)
+	layer.SetParent(w)
+}
+
+func (w *Window) RemoveOverlay(layer Layer) {
+	for idx := range w.overlays {
+		if w.overlays[idx] == layer {
+			w.overlays = append(w.overlays[:idx], w.overlays[idx+1:]...)
+			w.InvalidateRect(layer.Frame())
+			return
+		}
+	}
+}
+
+// MoveCursor moves the mouse cursor to pt, if ShowCursor is set.
+// Otherwise, the cursor is removed if it was shown.
+func (w *Window) MoveCursor(pt image.Point) {
+	if !w.ShowCursor {
+		if w.cursor != nil {
+			w.RemoveOverlay(w.cursor)
+			w.cursor = nil
+		}
+		return
+	}
+	if w.cursor == nil {
+		w.cursor = &CursorLayer{}
+		w.cursor.Init()
+		w.AddOverlay(w.cursor)
+	}
+	w.cursor.MoveTo(pt)
+}
+
 func (w *Window) InvalidateRect(rect image.Rectangle) {
 	w.invalid.AddRect(rect)
+	w.requestRedraw()
+}
 
+// flushRect flushes rect of the buffer again with the next frame, without redrawing it.
+func (w *Window) flushRect(rect image.Rectangle) {
+	w.Buffer.SetDirty(rect)
+	w.requestRedraw()
+}
+
+func (w *Window) requestRedraw() {
 	// This pattern sends a struct to the channel IFF it doesn't block.
 	// Since the channel capacity is 1, this means the channel send
 	// will succeed at most once per turn of the event loop
@@ -39,25 +104,61 @@ func (w *Window) InvalidateRect(rect image.Rectangle) {
 // update traverses the layer hierarchy, displaying any layers
 // that need to be displayed. If any layers are displayed, a
 // superset of all drawn rects is flushed to the display.
-func (w *Window) update(flush func(*image.RGBA)) {
+// flush returns the number of pixels it converted for the display.
+func (w *Window) update(flush func(frame *image.RGBA, rects []image.Rectangle) int) {
 	start := time.Now()
-	w.BufferedLayer.RenderBuffer()
+	rendered := w.renderBuffer()
 	w.checkRoundCorners()
+
+	frame, rects := w.Buffer.RGBA, w.dirty.Dequeue()
+	if w.debug != nil {
+		frame, rects = w.debug.composite(frame, rendered, rects)
+	}
 	drawn := time.Now()
 
-	rects := w.dirty.Dequeue()
+	stats := FrameStats{Start: start, DrawTime: drawn.Sub(start), DirtyRects: len(rects)}
 	for _, rect := range rects {
-		flush(w.Buffer.SubImage(rect).(*image.RGBA))
+		stats.DirtyArea += rect.Dx() * rect.Dy()
+	}
+	if len(rects) > 0 {
+		stats.PixelsConverted = flush(frame, rects)
+		// Frames are timed by the runloop, or by the clock when drawn without one
+		frameTime := start
+		if w.runloop != nil {
+			frameTime = w.runloop.lastFrame
+		}
+		w.flushSinks(frame, rects, frameTime)
+	}
+	stats.FlushTime = time.Since(drawn)
+
+	if len(rects) > 0 {
+		if w.debug != nil {
+			w.debug.record(stats)
+		}
+		if w.OnFrame != nil {
+			w.OnFrame(stats)
+		}
 	}
+}
 
-	if time.Since(start).Milliseconds() > 0 {
-		fmt.Printf(
-			"Updated: Draw %dms / Flush %dms in %v\n",
-			drawn.Sub(start).Milliseconds(),
-			time.Since(drawn).Milliseconds(),
-			rects,
-		)
+// renderBuffer renders invalid regions of the window, followed by its overlays,
+// and returns the regions rendered.
+func (w *Window) renderBuffer() []image.Rectangle {
+	// Copied, as rendering may invalidate other regions
+	rects := append([]image.Rectangle(nil), w.invalid.Dequeue()...)
+	for _, rect := range rects {
+		ctx := w.drawBuffer(rect)
+		if w.Visible() && w.transparency > 0 {
+			// Nothing is beneath the window, so fade it toward transparent, which displays show as black
+			fadeRect(ctx.Image(), ctx.Bounds(), 0xFF-w.transparency)
+		}
+		for _, overlay := range w.overlays {
+			if clipped := ctx.Clip(overlay.Frame()); !clipped.Bounds().Empty() {
+				overlay.Render(clipped)
+			}
+		}
 	}
+	return rects
 }
 
 func (w *Window) checkRoundCorners() {
@@ -83,5 +184,11 @@ func (w *Window) checkRoundCorners() {
 func (w *Window) Redraw(flush func(*image.RGBA)) {
 	w.Buffer.Reset(color.RGBA{})
 	w.Invalidate()
-	w.update(flush)
+	w.update(func(frame *image.RGBA, rects []image.Rectangle) (converted int) {
+		for _, rect := range rects {
+			flush(frame.SubImage(rect).(*image.RGBA))
+			converted += rect.Dx() * rect.Dy()
+		}
+		return
+	})
 }
@@ -0,0 +1,548 @@
+package touch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// X11Window shows a window on an X server, for developing on Linux desktops
+// without a device. It speaks the X protocol itself, so it needs neither cgo
+// nor Xlib, and runs under Xvfb. The X window takes the place of the device's
+// screen: it receives the frames of a window on a headless display of the same size:
+//
+//	display.InitHeadless(320, 480)
+//	xwin, err := touch.OpenX11Window(&touch.MainRunLoop, "", display.Size)
+//	...
+//	window.Init(display)
+//	window.AddSink(xwin)
+//
+// Button 1 is delivered as touches, buttons 4 and 5 (the scroll wheel) as
+// KeyPrevious and KeyNext, and X keycodes as the Linux key codes they're offset from.
+type X11Window struct {
+	runloop *RunLoop
+	conn    net.Conn
+	done    chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
+
+	// Guards writes to conn, and the mirror of the window's contents used to redraw exposed regions
+	mu     sync.Mutex
+	mirror *image.RGBA
+
+	root, black   uint32
+	window, gc    uint32
+	maxRequest    int // bytes
+	msbFirst      bool
+	protocolsAtom uint32
+	deleteAtom    uint32
+
+	// Input state, owned by the event loop
+	pressed  bool
+	position image.Point
+	keysDown map[byte]bool
+}
+
+var errX11Closed = errors.New("X11: window closed")
+
+// Core protocol opcodes, events and predefined atoms
+const (
+	x11CreateWindow   = 1
+	x11MapWindow      = 8
+	x11InternAtom     = 16
+	x11ChangeProperty = 18
+	x11CreateGC       = 55
+	x11PutImage       = 72
+
+	x11Error         = 0
+	x11Reply         = 1
+	x11KeyPress      = 2
+	x11KeyRelease    = 3
+	x11ButtonPress   = 4
+	x11ButtonRelease = 5
+	x11MotionNotify  = 6
+	x11Expose        = 12
+	x11ClientMessage = 33
+
+	x11AtomAtom        = 4
+	x11AtomString      = 31
+	x11AtomWMName      = 39
+	x11AtomNormalHints = 40
+	x11AtomSizeHints   = 41
+
+	// Evdev key codes are offset by 8 in X keycodes
+	x11KeycodeOffset = 8
+)
+
+// OpenX11Window connects to display, or $DISPLAY if empty, and opens a window
+// of the given size that delivers its input to runloop.
+func OpenX11Window(runloop *RunLoop, display string, size image.Point) (*X11Window, error) {
+	conn, number, err := dialX11(display)
+	if err != nil {
+		return nil, err
+	}
+	x := &X11Window{
+		runloop:  runloop,
+		conn:     conn,
+		done:     make(chan struct{}),
+		mirror:   image.NewRGBA(image.Rectangle{Max: size}),
+		keysDown: map[byte]bool{},
+	}
+	if err := x.setup(number, size); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go x.eventLoop()
+	return x, nil
+}
+
+// Done is closed once the window is closed, or the connection to the X server is lost.
+func (x *X11Window) Done() <-chan struct{} {
+	return x.done
+}
+
+// Close closes the window and the connection to the X server.
+func (x *X11Window) Close() error {
+	x.closeOnce.Do(func() {
+		close(x.done)
+		x.closeErr = x.conn.Close()
+	})
+	return x.closeErr
+}
+
+// SetTitle sets the window's title.
+func (x *X11Window) SetTitle(title string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.send(x11ChangePropertyRequest(x.window, x11AtomWMName, x11AtomString, 8, []byte(title)))
+}
+
+// Flush copies the updated rects of buf to the window.
+func (x *X11Window) Flush(buf *image.RGBA, rects []image.Rectangle) error {
+	select {
+	case <-x.done:
+		return errX11Closed
+	default:
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, rect := range rects {
+		rect = rect.Intersect(x.mirror.Rect)
+		draw.Draw(x.mirror, rect, buf, rect.Min, draw.Src)
+		if err := x.putImage(rect); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dialX11 connects to the X server for display, returning the display number for authorization.
+func dialX11(display string) (net.Conn, string, error) {
+	if display == "" {
+		display = os.Getenv("DISPLAY")
+	}
+	colon := strings.LastIndex(display, ":")
+	if colon < 0 {
+		return nil, "", fmt.Errorf("X11: invalid display %q", display)
+	}
+	host, number := display[:colon], display[colon+1:]
+	if dot := strings.Index(number, "."); dot >= 0 {
+		number = number[:dot]
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return nil, "", fmt.Errorf("X11: invalid display %q", display)
+	}
+
+	var conn net.Conn
+	switch {
+	case host == "" || host == "unix":
+		conn, err = net.Dial("unix", "/tmp/.X11-unix/X"+number)
+	case strings.HasPrefix(host, "/"):
+		// A socket path, as used by XQuartz
+		conn, err = net.Dial("unix", display)
+	default:
+		conn, err = net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(6000+n)))
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("X11: %w", err)
+	}
+	return conn, number, nil
+}
+
+// xauthority returns the authorization for a local display number from the user's Xauthority file.
+func xauthority(number string) (name, data []byte) {
+	path := os.Getenv("XAUTHORITY")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		path = filepath.Join(home, ".Xauthority")
+	}
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil
+	}
+	hostname, _ := os.Hostname()
+
+	const familyLocal, familyWild = 256, 0xFFFF
+	r := bytes.NewReader(file)
+	readField := func() []byte {
+		var n uint16
+		if binary.Read(r, binary.BigEndian, &n) != nil || int(n) > r.Len() {
+			return nil
+		}
+		field := make([]byte, n)
+		r.Read(field)
+		return field
+	}
+	for r.Len() > 0 {
+		var family uint16
+		if binary.Read(r, binary.BigEndian, &family) != nil {
+			break
+		}
+		address, num, authName, authData := readField(), readField(), readField(), readField()
+		if family != familyWild && !(family == familyLocal && string(address) == hostname) {
+			continue
+		}
+		if (len(num) == 0 || string(num) == number) && string(authName) == "MIT-MAGIC-COOKIE-1" {
+			return authName, authData
+		}
+	}
+	return nil, nil
+}
+
+func (x *X11Window) setup(number string, size image.Point) error {
+	authName, authData := xauthority(number)
+	req := []byte{'l', 0}
+	req = x11Put16(req, 11)
+	req = x11Put16(req, 0)
+	req = x11Put16(req, uint16(len(authName)))
+	req = x11Put16(req, uint16(len(authData)))
+	req = x11Put16(req, 0)
+	req = x11Pad(append(req, authName...))
+	req = x11Pad(append(req, authData...))
+	if err := x.send(req); err != nil {
+		return err
+	}
+
+	var header [8]byte
+	if _, err := io.ReadFull(x.conn, header[:]); err != nil {
+		return fmt.Errorf("X11: %w", err)
+	}
+	info := make([]byte, 4*int(binary.LittleEndian.Uint16(header[6:])))
+	if _, err := io.ReadFull(x.conn, info); err != nil {
+		return fmt.Errorf("X11: %w", err)
+	}
+	if header[0] != 1 {
+		reason := info
+		if header[0] == 0 && int(header[1]) <= len(info) {
+			reason = info[:header[1]]
+		}
+		return fmt.Errorf("X11: connection refused: %s", strings.TrimSpace(string(reason)))
+	}
+
+	if err := x.parseSetup(info); err != nil {
+		return err
+	}
+	var err error
+	if x.protocolsAtom, err = x.internAtom("WM_PROTOCOLS"); err != nil {
+		return err
+	}
+	if x.deleteAtom, err = x.internAtom("WM_DELETE_WINDOW"); err != nil {
+		return err
+	}
+	return x.createWindow(size)
+}
+
+func (x *X11Window) parseSetup(info []byte) error {
+	le := binary.LittleEndian
+	if len(info) < 32 {
+		return errors.New("X11: short setup reply")
+	}
+	idBase := le.Uint32(info[4:])
+	vendorLen := int(le.Uint16(info[16:]))
+	x.maxRequest = 4 * int(le.Uint16(info[18:]))
+	numFormats := int(info[21])
+	x.msbFirst = info[22] == 1
+
+	offset := 32 + (vendorLen+3)&^3
+	if len(info) < offset+8*numFormats+40 {
+		return errors.New("X11: short setup reply")
+	}
+	formats := info[offset:]
+	screen := info[offset+8*numFormats:]
+	x.root = le.Uint32(screen[0:])
+	x.black = le.Uint32(screen[12:])
+	rootDepth := screen[38]
+
+	// The window buffer is converted to 32-bit TrueColor pixels, which nearly every server uses
+	var bpp byte
+	for i := 0; i < numFormats; i++ {
+		if f := formats[8*i:]; f[0] == rootDepth {
+			bpp = f[1]
+		}
+	}
+	if rootDepth != 24 || bpp != 32 {
+		return fmt.Errorf("X11: unsupported visual (depth %d, %d bits per pixel)", rootDepth, bpp)
+	}
+
+	x.window = idBase | 1
+	x.gc = idBase | 2
+	return nil
+}
+
+func (x *X11Window) internAtom(name string) (uint32, error) {
+	req := []byte{x11InternAtom, 0, 0, 0}
+	req = x11Put16(req, uint16(len(name)))
+	req = x11Put16(req, 0)
+	req = x11Pad(append(req, name...))
+	if err := x.send(x11SetLength(req)); err != nil {
+		return 0, err
+	}
+	for {
+		var reply [32]byte
+		if _, err := io.ReadFull(x.conn, reply[:]); err != nil {
+			return 0, fmt.Errorf("X11: %w", err)
+		}
+		switch reply[0] {
+		case x11Reply:
+			return binary.LittleEndian.Uint32(reply[8:]), nil
+		case x11Error:
+			return 0, fmt.Errorf("X11: error %d interning %s", reply[1], name)
+		}
+	}
+}
+
+func (x *X11Window) createWindow(size image.Point) error {
+	const (
+		cwBackPixel = 0x2
+		cwEventMask = 0x800
+
+		eventMask = 0x1 | 0x2 | // KeyPress, KeyRelease
+			0x4 | 0x8 | // ButtonPress, ButtonRelease
+			0x100 | // Button1Motion
+			0x8000 // Exposure
+	)
+	req := []byte{x11CreateWindow, 0, 0, 0}
+	req = x11Put32(req, x.window)
+	req = x11Put32(req, x.root)
+	req = x11Put32(req, 0) // x, y
+	req = x11Put16(req, uint16(size.X))
+	req = x11Put16(req, uint16(size.Y))
+	req = x11Put16(req, 0) // Border width
+	req = x11Put16(req, 1) // InputOutput
+	req = x11Put32(req, 0) // CopyFromParent visual
+	req = x11Put32(req, cwBackPixel|cwEventMask)
+	req = x11Put32(req, x.black)
+	req = x11Put32(req, eventMask)
+	if err := x.send(x11SetLength(req)); err != nil {
+		return err
+	}
+
+	// Fix the window's size, and ask the window manager to send WM_DELETE_WINDOW instead of disconnecting us
+	hints := make([]byte, 18*4)
+	binary.LittleEndian.PutUint32(hints[0:], 1<<4|1<<5) // PMinSize, PMaxSize
+	for i, v := range []int{size.X, size.Y, size.X, size.Y} {
+		binary.LittleEndian.PutUint32(hints[4*(5+i):], uint32(v))
+	}
+	protocols := x11Put32(nil, x.deleteAtom)
+
+	gc := []byte{x11CreateGC, 0, 0, 0}
+	gc = x11Put32(gc, x.gc)
+	gc = x11Put32(gc, x.window)
+	gc = x11Put32(gc, 0)
+
+	mapWindow := x11Put32([]byte{x11MapWindow, 0, 0, 0}, x.window)
+
+	for _, req := range [][]byte{
+		x11ChangePropertyRequest(x.window, x11AtomWMName, x11AtomString, 8, []byte("go-touch")),
+		x11ChangePropertyRequest(x.window, x11AtomNormalHints, x11AtomSizeHints, 32, hints),
+		x11ChangePropertyRequest(x.window, x.protocolsAtom, x11AtomAtom, 32, protocols),
+		x11SetLength(gc),
+		x11SetLength(mapWindow),
+	} {
+		if err := x.send(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func x11ChangePropertyRequest(window, property, typ uint32, format byte, data []byte) []byte {
+	req := []byte{x11ChangeProperty, 0, 0, 0}
+	req = x11Put32(req, window)
+	req = x11Put32(req, property)
+	req = x11Put32(req, typ)
+	req = append(req, format, 0, 0, 0)
+	req = x11Put32(req, uint32(len(data)*8/int(format)))
+	req = x11Pad(append(req, data...))
+	return x11SetLength(req)
+}
+
+// putImage sends the mirror's pixels in rect to the window, in as many requests as needed.
+// The caller must hold x.mu.
+func (x *X11Window) putImage(rect image.Rectangle) error {
+	const headerSize = 24
+	w := rect.Dx()
+	if w <= 0 || rect.Dy() <= 0 {
+		return nil
+	}
+	rowsPerRequest := (x.maxRequest - headerSize) / (4 * w)
+	if rowsPerRequest < 1 {
+		rowsPerRequest = 1
+	}
+
+	for y := rect.Min.Y; y < rect.Max.Y; y += rowsPerRequest {
+		h := rect.Max.Y - y
+		if h > rowsPerRequest {
+			h = rowsPerRequest
+		}
+		req := make([]byte, headerSize, headerSize+4*w*h)
+		req[0], req[1] = x11PutImage, 2 // ZPixmap
+		binary.LittleEndian.PutUint32(req[4:], x.window)
+		binary.LittleEndian.PutUint32(req[8:], x.gc)
+		binary.LittleEndian.PutUint16(req[12:], uint16(w))
+		binary.LittleEndian.PutUint16(req[14:], uint16(h))
+		binary.LittleEndian.PutUint16(req[16:], uint16(rect.Min.X))
+		binary.LittleEndian.PutUint16(req[18:], uint16(y))
+		req[21] = 24 // Depth
+
+		for row := y; row < y+h; row++ {
+			offset := x.mirror.PixOffset(rect.Min.X, row)
+			for px := x.mirror.Pix[offset : offset+4*w]; len(px) > 0; px = px[4:] {
+				if x.msbFirst {
+					req = append(req, 0, px[0], px[1], px[2])
+				} else {
+					req = append(req, px[2], px[1], px[0], 0)
+				}
+			}
+		}
+		if err := x.send(x11SetLength(req)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *X11Window) send(req []byte) error {
+	if _, err := x.conn.Write(req); err != nil {
+		return fmt.Errorf("X11: %w", err)
+	}
+	return nil
+}
+
+// eventLoop delivers input until the window is closed or the connection is lost.
+func (x *X11Window) eventLoop() {
+	defer x.Close()
+	le := binary.LittleEndian
+	for {
+		var ev [32]byte
+		if _, err := io.ReadFull(x.conn, ev[:]); err != nil {
+			break
+		}
+		switch ev[0] & 0x7F {
+		case x11Error:
+			fmt.Fprintf(os.Stderr, "X11: error %d for request %d\n", ev[1], ev[10])
+		case x11Reply:
+			// No requests with replies are sent once the window is open
+			io.CopyN(io.Discard, x.conn, 4*int64(le.Uint32(ev[4:])))
+		case x11KeyPress, x11KeyRelease:
+			x.handleKey(ev[1], ev[0]&0x7F == x11KeyPress)
+		case x11ButtonPress, x11ButtonRelease:
+			pt := image.Pt(int(int16(le.Uint16(ev[24:]))), int(int16(le.Uint16(ev[26:]))))
+			x.handleButton(ev[1], ev[0]&0x7F == x11ButtonPress, pt)
+		case x11MotionNotify:
+			if x.pressed {
+				x.position = image.Pt(int(int16(le.Uint16(ev[24:]))), int(int16(le.Uint16(ev[26:]))))
+				x.runloop.InjectTouch(TouchEvent{Point: x.position, Pressed: true, Pressure: 0xFF})
+			}
+		case x11Expose:
+			rect := image.Rect(0, 0, int(le.Uint16(ev[12:])), int(le.Uint16(ev[14:])))
+			rect = rect.Add(image.Pt(int(le.Uint16(ev[8:])), int(le.Uint16(ev[10:]))))
+			x.mu.Lock()
+			x.putImage(rect.Intersect(x.mirror.Rect))
+			x.mu.Unlock()
+		case x11ClientMessage:
+			if le.Uint32(ev[8:]) == x.protocolsAtom && le.Uint32(ev[12:]) == x.deleteAtom {
+				x.releaseAll()
+				return
+			}
+		}
+	}
+	// No releases can arrive from a lost server, so end the touch and key presses now
+	x.releaseAll()
+}
+
+func (x *X11Window) handleButton(button byte, pressed bool, pt image.Point) {
+	switch button {
+	case 1:
+		x.pressed = pressed
+		x.position = pt
+		x.runloop.InjectTouch(TouchEvent{Point: pt, Pressed: pressed, Pressure: 0xFF})
+	case 4, 5:
+		// Buttons 4 and 5 are the scroll wheel, pressed and released for each step up or down
+		if pressed && button == 4 {
+			x.runloop.InjectSteps(-1)
+		} else if pressed {
+			x.runloop.InjectSteps(1)
+		}
+	}
+}
+
+func (x *X11Window) handleKey(keycode byte, pressed bool) {
+	if keycode < x11KeycodeOffset {
+		return
+	}
+	// Autorepeat arrives as a press while the key is already down
+	repeat := pressed && x.keysDown[keycode]
+	x.keysDown[keycode] = pressed
+	x.runloop.InjectKey(KeyEvent{Key: Key(keycode - x11KeycodeOffset), Pressed: pressed, Repeat: repeat})
+}
+
+func (x *X11Window) releaseAll() {
+	if x.pressed {
+		x.pressed = false
+		x.runloop.InjectTouch(TouchEvent{Point: x.position, Pressure: 0xFF})
+	}
+	for keycode, down := range x.keysDown {
+		if down {
+			x.handleKey(keycode, false)
+		}
+	}
+}
+
+func x11Put16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func x11Put32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// x11Pad pads b to a multiple of 4 bytes.
+func x11Pad(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// x11SetLength stores the length of request req, in 4-byte units, in its header.
+func x11SetLength(req []byte) []byte {
+	binary.LittleEndian.PutUint16(req[2:], uint16(len(req)/4))
+	return req
+}
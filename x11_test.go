@@ -0,0 +1,160 @@
+package touch_test
+
+import (
+	"encoding/binary"
+	"image"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	touch "github.com/jyopp/go-touch"
+)
+
+// x11SetupReply returns a canned setup reply, after its 8-byte header,
+// for a server with one screen of the given root depth.
+func x11SetupReply(rootDepth byte) []byte {
+	le := binary.LittleEndian
+	info := make([]byte, 32)
+	le.PutUint32(info[4:], 0x04000000) // resource-id-base
+	le.PutUint16(info[16:], 5)         // vendor length
+	le.PutUint16(info[18:], 0xFFFF)    // maximum request length, in 4-byte units
+	info[20] = 1                       // screens
+	info[21] = 2                       // pixmap formats
+	info = append(info, "Xtest\x00\x00\x00"...)
+	// Pixmap formats: depth, bits per pixel, scanline pad
+	info = append(info, 1, 1, 32, 0, 0, 0, 0, 0)
+	info = append(info, 24, 32, 32, 0, 0, 0, 0, 0)
+
+	screen := make([]byte, 40)
+	le.PutUint32(screen[0:], 0x1AB)     // root
+	le.PutUint32(screen[8:], 0xFFFFFF)  // white pixel
+	le.PutUint32(screen[12:], 0x000000) // black pixel
+	screen[38] = rootDepth
+	return append(info, screen...)
+}
+
+// x11Event returns a 32-byte input event with a detail byte, and event-x and event-y.
+func x11Event(code, detail byte, x, y int16) []byte {
+	ev := make([]byte, 32)
+	ev[0], ev[1] = code, detail
+	binary.LittleEndian.PutUint16(ev[24:], uint16(x))
+	binary.LittleEndian.PutUint16(ev[26:], uint16(y))
+	return ev
+}
+
+func TestX11Setup(t *testing.T) {
+	t.Run("Setup Reply", func(t *testing.T) {
+		x := touch.NewTestX11Window(nil, nil, image.Pt(32, 32))
+		if err := x.ParseSetup(x11SetupReply(24)); err != nil {
+			t.Fatal(err)
+		}
+		root, black, window, gc, maxRequest := x.SetupResult()
+		if root != 0x1AB || black != 0 {
+			t.Errorf("Unexpected root %#x and black pixel %#x", root, black)
+		}
+		if window != 0x04000001 || gc != 0x04000002 {
+			t.Errorf("Expected IDs allocated from the resource base, got %#x and %#x", window, gc)
+		}
+		if maxRequest != 4*0xFFFF {
+			t.Errorf("Expected the maximum request length in bytes, got %d", maxRequest)
+		}
+	})
+	t.Run("Unsupported Depth", func(t *testing.T) {
+		x := touch.NewTestX11Window(nil, nil, image.Pt(32, 32))
+		if err := x.ParseSetup(x11SetupReply(16)); err == nil {
+			t.Error("Expected a 16-bit root visual to be rejected")
+		}
+	})
+	t.Run("Short Reply", func(t *testing.T) {
+		x := touch.NewTestX11Window(nil, nil, image.Pt(32, 32))
+		reply := x11SetupReply(24)
+		if err := x.ParseSetup(reply[:len(reply)-1]); err == nil {
+			t.Error("Expected a truncated reply to be rejected")
+		}
+	})
+}
+
+func TestX11Events(t *testing.T) {
+	runloop := startHeadless(t, nil)
+	layer := &keyLayer{}
+	runloop.Do(func() error {
+		layer.Self = layer
+		layer.SetFrame(image.Rect(0, 0, 32, 32))
+		runloop.Window.AddChild(layer)
+		runloop.Window.SetFocus(layer)
+		return nil
+	})
+	server, conn := net.Pipe()
+	defer server.Close()
+	x := touch.NewTestX11Window(runloop, conn, image.Pt(32, 32))
+	const protocols, deleteWindow = 100, 101
+	x.SetDeleteAtoms(protocols, deleteWindow)
+	go x.EventLoop()
+
+	const keyPress, keyRelease, buttonPress, buttonRelease, motionNotify, clientMessage = 2, 3, 4, 5, 6, 33
+	closeEvent := make([]byte, 32)
+	closeEvent[0] = clientMessage
+	binary.LittleEndian.PutUint32(closeEvent[8:], protocols)
+	binary.LittleEndian.PutUint32(closeEvent[12:], deleteWindow)
+	// The send_event flag is ignored
+	sent := x11Event(buttonRelease, 1, 12, 22)
+	sent[0] |= 0x80
+	for _, ev := range [][]byte{
+		x11Event(buttonPress, 1, 10, 20),
+		x11Event(motionNotify, 0, 12, 22),
+		sent,
+		// Motion without a button pressed is ignored
+		x11Event(motionNotify, 0, 14, 24),
+		x11Event(buttonPress, 4, 0, 0),
+		x11Event(buttonRelease, 4, 0, 0),
+		x11Event(buttonPress, 5, 0, 0),
+		x11Event(buttonRelease, 5, 0, 0),
+		x11Event(buttonPress, 1, 5, 6),
+		// Enter, with an autorepeat
+		x11Event(keyPress, 28+8, 0, 0),
+		x11Event(keyPress, 28+8, 0, 0),
+		closeEvent,
+	} {
+		if _, err := server.Write(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-x.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected the window to close")
+	}
+	waitForTasks(runloop)
+
+	var keys []touch.KeyEvent
+	var touches []touch.TouchEvent
+	runloop.Do(func() error {
+		keys, touches = layer.keys, layer.events
+		return nil
+	})
+	for idx := range touches {
+		touches[idx].Cancel = nil
+	}
+	expectedTouches := []touch.TouchEvent{
+		{Point: image.Pt(10, 20), Pressed: true, Pressure: 0xFF},
+		{Point: image.Pt(12, 22), Pressed: true, Pressure: 0xFF},
+		{Point: image.Pt(12, 22), Pressure: 0xFF},
+		{Point: image.Pt(5, 6), Pressed: true, Pressure: 0xFF},
+		// Released when the window closes
+		{Point: image.Pt(5, 6), Pressure: 0xFF},
+	}
+	if !reflect.DeepEqual(touches, expectedTouches) {
+		t.Errorf("Expected touches %+v, got %+v", expectedTouches, touches)
+	}
+	expectedKeys := []touch.KeyEvent{
+		{Key: touch.KeyPrevious, Pressed: true}, {Key: touch.KeyPrevious},
+		{Key: touch.KeyNext, Pressed: true}, {Key: touch.KeyNext},
+		{Key: touch.KeyEnter, Pressed: true},
+		{Key: touch.KeyEnter, Pressed: true, Repeat: true},
+		{Key: touch.KeyEnter},
+	}
+	if !reflect.DeepEqual(keys, expectedKeys) {
+		t.Errorf("Expected keys %+v, got %+v", expectedKeys, keys)
+	}
+}